@@ -0,0 +1,107 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DefaultArrayBindThreshold rows from which Create binds one array per column when Config.ArrayBindThreshold is not set
+const DefaultArrayBindThreshold = 100
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// arrayBindThreshold rows from which Create binds arrays, 0 when disabled
+func (config *Config) arrayBindThreshold() int {
+	switch {
+	case config.ArrayBindThreshold < 0:
+		return 0
+	case config.ArrayBindThreshold == 0:
+		return DefaultArrayBindThreshold
+	}
+	return config.ArrayBindThreshold
+}
+
+// arrayBindValues one array per column holding the value of every row, for gosnowflake's array binding
+// (the driver uploads them to a temporary stage above CLIENT_STAGE_ARRAY_BINDING_THRESHOLD).
+// Returns false when there are too few rows or a value can't be bound in an array (e.g. expressions), the rows are
+// then inserted with a VALUES list. Database defaults read back after the insert are matched by the inserted values,
+// which staged binds keep.
+func arrayBindValues(db *gorm.DB, values clause.Values) ([]interface{}, bool) {
+	threshold := configOf(db).arrayBindThreshold()
+	if threshold == 0 || len(values.Values) < threshold || len(values.Columns) == 0 {
+		return nil, false
+	}
+
+	arrays := make([]interface{}, len(values.Columns))
+	for idx, column := range values.Columns {
+		var field *schema.Field
+		if db.Statement.Schema != nil {
+			field = db.Statement.Schema.LookUpField(column.Name)
+		}
+
+		array := make([]interface{}, len(values.Values))
+		for row, value := range values.Values {
+			v, ok := arrayBindValue(db, field, value[idx])
+			if !ok {
+				return nil, false
+			}
+			array[row] = v
+		}
+		arrays[idx] = &array
+	}
+	return arrays, true
+}
+
+// arrayBindValue convert a value to one of the types gosnowflake binds in an []interface{} array,
+// times are bound as text in the format of the column
+func arrayBindValue(db *gorm.DB, field *schema.Field, value interface{}) (interface{}, bool) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return nil, false
+		}
+		value = v
+	}
+
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, true
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, true
+	case reflect.Bool:
+		return rv.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return strconv.FormatUint(rv.Uint(), 10), true
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), true
+		}
+	case reflect.Struct:
+		if rv.Type().ConvertibleTo(timeType) {
+			return formatTime(db, field, rv.Convert(timeType).Interface().(time.Time)), true
+		}
+	}
+	return nil, false
+}
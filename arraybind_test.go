@@ -0,0 +1,47 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+func TestArrayBindReadsDefaultsBack(t *testing.T) {
+	table := &changesTable{shuffled: true}
+	db, d := openFake(t, Config{ArrayBindThreshold: 2}, table.handle)
+
+	users := []changeUser{{Name: "alice"}, {Name: "bob"}, {Name: "carol"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"INSERT INTO change_users (name) VALUES (?);",
+		"SELECT id,name FROM change_users CHANGES(INFORMATION => APPEND_ONLY)",
+	)
+	for _, user := range users {
+		if name := table.nameOf(user.ID); name != user.Name {
+			t.Errorf("%s got the ID %d of %q", user.Name, user.ID, name)
+		}
+	}
+}
+
+func TestArrayBindWithSequenceIDs(t *testing.T) {
+	db, d := openFake(t, Config{ArrayBindThreshold: 2, IDStrategy: IDSequence}, func(statement fakeStatement) (*fakeRows, error) {
+		if strings.Contains(statement.query, "NEXTVAL") {
+			return &fakeRows{columns: []string{"NEXTVAL"}, values: [][]driver.Value{{"7"}, {"8"}}}, nil
+		}
+		return nil, nil
+	})
+
+	users := []changeUser{{Name: "alice"}, {Name: "bob"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"SELECT change_users_id_seq.NEXTVAL FROM TABLE(GENERATOR(ROWCOUNT => 2))",
+		"INSERT INTO change_users (name,id) VALUES (?,?);",
+	)
+	if users[0].ID != 7 || users[1].ID != 8 {
+		t.Errorf("IDs = %d, %d", users[0].ID, users[1].ID)
+	}
+}
@@ -0,0 +1,125 @@
+package snowflake
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// CreateInBatches like db.CreateInBatches, but runs the batches concurrently on Config.CreateBatchWorkers
+// pinned connections, each batch inserting and reading back its default values on its own session, e.g.
+//
+//	err := snowflake.CreateInBatches(db, &events, 10000).Error
+//
+// Batches aren't created in one transaction: when one fails, the batches not started yet are skipped and the
+// error of the first failing batch (in slice order) is returned. A connection that couldn't be released after its
+// batches ran adds its error without replacing theirs. Without workers, inside a transaction or WithSession, it falls
+// back to db.CreateInBatches. Arrays are passed by pointer.
+func CreateInBatches(db *gorm.DB, value interface{}, batchSize int) *gorm.DB {
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	if rv.Kind() == reflect.Array && !rv.CanAddr() {
+		// batches are slices of value, an array has to be passed by pointer
+		tx := db.Session(&gorm.Session{})
+		_ = tx.AddError(fmt.Errorf("%w: pass a pointer to the %s", gorm.ErrInvalidValue, rv.Type()))
+		return tx
+	}
+
+	workers := configOf(db).CreateBatchWorkers
+	if _, pooled := db.Statement.ConnPool.(connPooler); !pooled || workers <= 1 || batchSize <= 0 ||
+		(rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Len() <= batchSize {
+		return db.CreateInBatches(value, batchSize)
+	}
+
+	batches := (rv.Len() + batchSize - 1) / batchSize
+	if workers > batches {
+		workers = batches
+	}
+
+	var (
+		errs         = make([]error, batches)
+		jobs         = make(chan int)
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		failed       bool
+		pinErr       error
+		releaseErr   error
+		rowsAffected int64
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			pinned := false
+			err := WithSession(db, func(tx *gorm.DB) error {
+				pinned = true
+				for batch := range jobs {
+					mu.Lock()
+					skip := failed
+					mu.Unlock()
+					if skip {
+						continue
+					}
+
+					start, end := batch*batchSize, (batch+1)*batchSize
+					if end > rv.Len() {
+						end = rv.Len()
+					}
+
+					result := tx.Create(rv.Slice(start, end).Interface())
+
+					mu.Lock()
+					rowsAffected += result.RowsAffected
+					if result.Error != nil {
+						errs[batch] = fmt.Errorf("snowflake: batch %d (rows %d to %d): %w", batch, start, end-1, result.Error)
+						failed = true
+					}
+					mu.Unlock()
+				}
+				return nil
+			})
+
+			switch {
+			case err != nil && !pinned:
+				// the connection couldn't be pinned, stop dispatching
+				mu.Lock()
+				if pinErr == nil {
+					pinErr = fmt.Errorf("snowflake: pinning a connection for the batches: %w", err)
+				}
+				failed = true
+				mu.Unlock()
+				for range jobs {
+				}
+			case err != nil:
+				// the batches of the connection ran, only returning it to the pool failed
+				mu.Lock()
+				if releaseErr == nil {
+					releaseErr = fmt.Errorf("snowflake: releasing the connection of the batches: %w", err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for batch := 0; batch < batches; batch++ {
+		jobs <- batch
+	}
+	close(jobs)
+	wg.Wait()
+
+	tx := db.Session(&gorm.Session{})
+	tx.RowsAffected = rowsAffected
+	for _, err := range append(errs, pinErr) {
+		if err != nil {
+			_ = tx.AddError(err)
+			break
+		}
+	}
+	if releaseErr != nil {
+		_ = tx.AddError(releaseErr)
+	}
+	return tx
+}
@@ -0,0 +1,54 @@
+package snowflake
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestCreateInBatchesArrays(t *testing.T) {
+	table := &changesTable{}
+	db, _ := openFake(t, Config{CreateBatchWorkers: 2}, table.handle)
+
+	users := [3]changeUser{{Name: "alice"}, {Name: "bob"}, {Name: "carol"}}
+	if err := CreateInBatches(db, users, 2).Error; !errors.Is(err, gorm.ErrInvalidValue) {
+		t.Errorf("array by value: %v", err)
+	}
+
+	if err := CreateInBatches(db, &users, 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range users {
+		if name := table.nameOf(user.ID); name != user.Name {
+			t.Errorf("%s got the ID %d of %q", user.Name, user.ID, name)
+		}
+	}
+}
+
+func TestCreateInBatchesPinFailure(t *testing.T) {
+	table := &changesTable{}
+	db, d := openFake(t, Config{CreateBatchWorkers: 2}, table.handle)
+
+	// drop the connection gorm opened so the workers have to connect
+	pool, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.SetMaxIdleConns(0)
+
+	connectErr := errors.New("no connection")
+	d.mu.Lock()
+	d.connectErr = connectErr
+	d.mu.Unlock()
+
+	users := []changeUser{{Name: "alice"}, {Name: "bob"}, {Name: "carol"}}
+	err = CreateInBatches(db, &users, 2).Error
+	if !errors.Is(err, connectErr) || !strings.Contains(err.Error(), "pinning a connection") {
+		t.Errorf("error = %v", err)
+	}
+	if queries := d.queries(); len(queries) > 0 {
+		t.Errorf("queries = %v", queries)
+	}
+}
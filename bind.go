@@ -0,0 +1,133 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// bindExprOf the expression converting a value bound for field into its column type, "?" when it's bound as is
+func bindExprOf(db *gorm.DB, field *schema.Field) string {
+	if field == nil {
+		return "?"
+	}
+	if mapping, ok := configOf(db).Types.lookUp(field); ok && mapping.BindExpr != "" {
+		return mapping.BindExpr
+	}
+
+	switch dataType := strings.ToUpper(db.Dialector.DataTypeOf(field)); {
+	case dataType == "VARIANT" && field.DataType == schema.Bytes:
+		// json.RawMessage
+		return "PARSE_JSON(TO_VARCHAR(?, 'UTF-8'))"
+	case dataType == "VARIANT":
+		return "PARSE_JSON(?)"
+	case strings.HasPrefix(dataType, "OBJECT"):
+		return "PARSE_JSON(?)::" + dataType
+	case strings.HasPrefix(dataType, "ARRAY"):
+		return "PARSE_JSON(?)::" + dataType
+	case strings.HasPrefix(dataType, "GEOGRAPHY"):
+		return "TO_GEOGRAPHY(?)"
+	case strings.HasPrefix(dataType, "GEOMETRY"):
+		return "TO_GEOMETRY(?)"
+	case strings.HasPrefix(dataType, "VECTOR"):
+		return "PARSE_JSON(?)::ARRAY::" + dataType
+	}
+	return "?"
+}
+
+// bindExprs the bind expressions of the inserted columns, nil when every value is bound as is
+func bindExprs(db *gorm.DB, columns []clause.Column) []string {
+	if db.Statement.Schema == nil {
+		return nil
+	}
+
+	var (
+		exprs   = make([]string, len(columns))
+		convert = false
+	)
+	for idx, column := range columns {
+		exprs[idx] = bindExprOf(db, db.Statement.Schema.LookUpField(column.Name))
+		convert = convert || exprs[idx] != "?"
+	}
+
+	if !convert {
+		return nil
+	}
+	return exprs
+}
+
+// writeSelectValues write the rows as SELECT <expr>($1), ... FROM VALUES (...), (...), converting the bound
+// values with their expressions as Snowflake only accepts constants in a VALUES list. Columns are aliased
+// with their names when aliased, e.g. for the source of a MERGE.
+func writeSelectValues(db *gorm.DB, values clause.Values, exprs []string, aliased bool) {
+	db.Statement.WriteString("SELECT ")
+	for idx, column := range values.Columns {
+		if idx > 0 {
+			db.Statement.WriteByte(',')
+		}
+		db.Statement.WriteString(strings.ReplaceAll(exprs[idx], "?", "$"+strconv.Itoa(idx+1)))
+		if aliased {
+			db.Statement.WriteString(" AS ")
+			db.Statement.WriteQuoted(column.Name)
+		}
+	}
+
+	db.Statement.WriteString(" FROM VALUES ")
+	for idx, value := range values.Values {
+		if idx > 0 {
+			db.Statement.WriteByte(',')
+		}
+
+		db.Statement.WriteByte('(')
+		for i, v := range value {
+			if i > 0 {
+				db.Statement.WriteByte(',')
+			}
+			if exprs[i] != "?" {
+				v = bindValue(v)
+			}
+			db.Statement.AddVar(db.Statement, v)
+		}
+		db.Statement.WriteByte(')')
+	}
+}
+
+// bindValue the driver value of v, rather than the expression of its GormValue, to be converted by a bind expression
+func bindValue(v interface{}) interface{} {
+	valuer, ok := v.(driver.Valuer)
+	if !ok {
+		// bytes (e.g. json.RawMessage) as one value, other maps and slices (e.g. of a type:variant field) as JSON
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.Slice, reflect.Map:
+			if rv.IsNil() {
+				return nil
+			}
+			if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+				return rv.Bytes()
+			}
+			fallthrough
+		case reflect.Array:
+			value, err := jsonValue(false, v)
+			if err != nil {
+				return invalidValue{err}
+			}
+			return value
+		}
+		return v
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	value, err := valuer.Value()
+	if err != nil {
+		return invalidValue{err}
+	}
+	return value
+}
@@ -0,0 +1,84 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// jsonAttrs a map with its own Valuer and Scanner, like datatypes.JSONMap
+type jsonAttrs map[string]interface{}
+
+func (jsonAttrs) GormDataType() string {
+	return "json"
+}
+
+func (a jsonAttrs) Value() (driver.Value, error) {
+	return jsonValue(a == nil, map[string]interface{}(a))
+}
+
+func (a *jsonAttrs) Scan(src interface{}) error {
+	return scanJSON(src, (*map[string]interface{})(a))
+}
+
+type variantDoc struct {
+	ID    int64 `gorm:"autoIncrement:false"`
+	Attrs jsonAttrs
+	Tags  []string               `gorm:"type:array"`
+	Extra map[string]interface{} `gorm:"type:object"`
+	Nums  []int                  `gorm:"serializer:json"`
+	Code  string                 `gorm:"type:varchar(8)"`
+}
+
+func TestDataTypeOfMapsAndSlices(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&variantDoc{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{"Attrs": "VARIANT", "Tags": "ARRAY", "Extra": "OBJECT", "Nums": "VARIANT"} {
+		if got := db.Dialector.DataTypeOf(stmt.Schema.LookUpField(name)); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestCreateBindsMapsAndSlicesAsJSON(t *testing.T) {
+	types := NewTypeRegistry()
+	types.Register("varchar(8)", TypeMapping{BindExpr: "IFF(? = '', NULL, UPPER(?))"})
+	db, d := openFake(t, Config{Types: types}, nil)
+
+	doc := variantDoc{ID: 1, Attrs: jsonAttrs{"a": 1}, Tags: []string{"x", "y"}, Extra: map[string]interface{}{"b": true}, Nums: []int{1}, Code: "ab"}
+	if err := db.Create(&doc).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(), "INSERT INTO variant_docs (id,attrs,tags,extra,nums,code) SELECT $1,PARSE_JSON($2),PARSE_JSON($3)::ARRAY,PARSE_JSON($4)::OBJECT,PARSE_JSON($5),IFF($6 = '', NULL, UPPER($6)) FROM VALUES (?,?,?,?,?,?);")
+
+	args := d.statements[0].args
+	for idx, want := range []interface{}{int64(1), `{"a":1}`, `["x","y"]`, `{"b":true}`, `[1]`, "ab"} {
+		if !reflect.DeepEqual(args[idx], want) {
+			data, _ := json.Marshal(args)
+			t.Fatalf("args = %s", data)
+		}
+	}
+}
+
+func TestCreateBindsLargeUnsignedIntegersAsText(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+
+	counter := sizedIntegers{ID: math.MaxUint64, Count: 7, Exact: math.MaxInt64}
+	if err := db.Create(&counter).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(), "INSERT INTO sized_integers (id,tiny,small,medium,count,signed,big,exact) VALUES (?,?,?,?,?,?,?,?);")
+
+	want := []interface{}{"18446744073709551615", int64(0), int64(0), int64(0), int64(7), int64(0), int64(0), int64(math.MaxInt64)}
+	if args := d.statements[0].args; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+}
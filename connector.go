@@ -0,0 +1,86 @@
+package snowflake
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/snowflakedb/gosnowflake"
+)
+
+// TokenProvider returns an OAuth access token, it is called every time the pool opens a new connection
+type TokenProvider func(ctx context.Context) (string, error)
+
+// connector opens snowflake connections from a structured config instead of a DSN
+type connector struct {
+	config        gosnowflake.Config
+	tokenProvider TokenProvider
+}
+
+func newConnector(config gosnowflake.Config, tokenProvider TokenProvider) *connector {
+	return &connector{config: config, tokenProvider: tokenProvider}
+}
+
+// Connect logs in with a copy of the config, fetching a fresh token first when a provider is set
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	config, err := c.loginConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gosnowflake.NewConnector(gosnowflake.SnowflakeDriver{}, config).Connect(ctx)
+}
+
+// loginConfig the config of a new connection, with the provider's token
+func (c *connector) loginConfig(ctx context.Context) (gosnowflake.Config, error) {
+	config := c.config
+
+	// gosnowflake writes session parameters back into Params after login, never share the map between connections
+	config.Params = make(map[string]*string, len(c.config.Params))
+	for k, v := range c.config.Params {
+		config.Params[k] = v
+	}
+
+	if c.tokenProvider != nil {
+		token, err := c.tokenProvider(ctx)
+		if err != nil {
+			return gosnowflake.Config{}, fmt.Errorf("snowflake: failed to get token: %w", err)
+		}
+
+		config.Token = token
+		if config.Authenticator == gosnowflake.AuthTypeSnowflake {
+			config.Authenticator = gosnowflake.AuthTypeOAuth
+		}
+	}
+	return config, nil
+}
+
+// Driver returns the underlying snowflake driver
+func (c *connector) Driver() driver.Driver {
+	return gosnowflake.SnowflakeDriver{}
+}
+
+// ParsePrivateKey parse a PEM encoded PKCS#8 (or PKCS#1) RSA private key for key-pair authentication
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("snowflake: no PEM block found in private key")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return rsaKey, nil
+	}
+	return nil, errors.New("snowflake: private key is not an RSA key")
+}
@@ -0,0 +1,114 @@
+package snowflake
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/snowflakedb/gosnowflake"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, block := range []*pem.Block{
+		{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		{Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		parsed, err := ParsePrivateKey(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Errorf("%s: %v", block.Type, err)
+		} else if !parsed.Equal(key) {
+			t.Errorf("%s: parsed another key", block.Type)
+		}
+	}
+}
+
+func TestParsePrivateKeyRefusesOtherKeys(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"ecdsa":   pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		"no pem":  []byte("not a key"),
+		"garbage": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}),
+	} {
+		if _, err := ParsePrivateKey(data); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+	if _, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})); err == nil || !strings.Contains(err.Error(), "not an RSA key") {
+		t.Errorf("ecdsa: %v", err)
+	}
+}
+
+func TestConnectorTokenProvider(t *testing.T) {
+	calls := 0
+	provider := func(context.Context) (string, error) {
+		calls++
+		return fmt.Sprintf("token-%d", calls), nil
+	}
+
+	timezone := "UTC"
+	c := newConnector(gosnowflake.Config{Account: "acme", Params: map[string]*string{"timezone": &timezone}}, provider)
+	first, err := c.loginConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.loginConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh token per connection, with OAuth instead of the default password login
+	if first.Token != "token-1" || second.Token != "token-2" || first.Authenticator != gosnowflake.AuthTypeOAuth {
+		t.Errorf("configs = %+v, %+v", first, second)
+	}
+	first.Params["timezone"] = nil
+	if c.config.Params["timezone"] != &timezone || second.Params["timezone"] != &timezone {
+		t.Error("params shared between connections")
+	}
+
+	// other authenticators keep theirs, e.g. key pairs with a token for a proxy
+	c = newConnector(gosnowflake.Config{Authenticator: gosnowflake.AuthTypeJwt}, provider)
+	if config, err := c.loginConfig(context.Background()); err != nil || config.Authenticator != gosnowflake.AuthTypeJwt {
+		t.Errorf("jwt config = %+v, %v", config, err)
+	}
+
+	// without a provider the config is left as is
+	c = newConnector(gosnowflake.Config{Token: "static"}, nil)
+	if config, err := c.loginConfig(context.Background()); err != nil || config.Token != "static" || config.Authenticator != gosnowflake.AuthTypeSnowflake {
+		t.Errorf("static config = %+v, %v", config, err)
+	}
+}
+
+func TestConnectorTokenProviderError(t *testing.T) {
+	providerErr := errors.New("token expired")
+	c := newConnector(gosnowflake.Config{}, func(context.Context) (string, error) {
+		return "", providerErr
+	})
+	if _, err := c.Connect(context.Background()); !errors.Is(err, providerErr) {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -1,7 +1,12 @@
 package snowflake
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"reflect"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/callbacks"
@@ -9,7 +14,15 @@ import (
 	"gorm.io/gorm/schema"
 )
 
+// changesOfLastQuery bound CHANGES to the rows written by the last statement of the session, without END the
+// rows committed by other sessions since then are selected too
+const changesOfLastQuery = " BEFORE(statement=>LAST_QUERY_ID()) END(statement=>LAST_QUERY_ID())"
+
 func Create(db *gorm.DB) {
+	var (
+		merged  bool
+		columns []clause.Column
+	)
 	if db.Statement.Schema != nil && !db.Statement.Unscoped {
 		for _, c := range db.Statement.Schema.CreateClauses {
 			db.Statement.AddClause(c)
@@ -17,11 +30,17 @@ func Create(db *gorm.DB) {
 	}
 
 	if db.Statement.SQL.String() == "" {
+		if sch := db.Statement.Schema; sch != nil && !db.DryRun && configOf(db).usesSequence(sch.PrioritizedPrimaryField) {
+			allocateSequenceIDs(db, sch.PrioritizedPrimaryField)
+		}
+
 		var (
 			values                  = callbacks.ConvertToCreateValues(db.Statement)
 			c                       = db.Statement.Clauses["ON CONFLICT"]
 			onConflict, hasConflict = c.Expression.(clause.OnConflict)
 		)
+		bindTimeValues(db, values)
+		columns = values.Columns
 
 		if hasConflict {
 			if len(db.Statement.Schema.PrimaryFields) > 0 {
@@ -41,6 +60,7 @@ func Create(db *gorm.DB) {
 		}
 
 		if hasConflict {
+			merged = true
 			MergeCreate(db, onConflict, values)
 		} else {
 			db.Statement.AddClauseIfNotExists(clause.Insert{})
@@ -59,16 +79,33 @@ func Create(db *gorm.DB) {
 					}
 					db.Statement.WriteByte(')')
 
-					db.Statement.WriteString(" VALUES ")
-
-					for idx, value := range values.Values {
-						if idx > 0 {
-							db.Statement.WriteByte(',')
-						}
-
+					if exprs := bindExprs(db, values.Columns); exprs != nil {
+						// values converted by expressions (e.g. PARSE_JSON) can't be in a VALUES list
+						db.Statement.WriteByte(' ')
+						writeSelectValues(db, values, exprs, false)
+					} else if arrays, ok := arrayBindValues(db, values); ok {
+						db.Statement.WriteString(" VALUES ")
+						// bind one array per column instead of a tuple per row
 						db.Statement.WriteByte('(')
-						db.Statement.AddVar(db.Statement, value...)
+						for idx, array := range arrays {
+							if idx > 0 {
+								db.Statement.WriteByte(',')
+							}
+							db.Statement.Vars = append(db.Statement.Vars, array)
+							db.Statement.WriteByte('?')
+						}
 						db.Statement.WriteByte(')')
+					} else {
+						db.Statement.WriteString(" VALUES ")
+						for idx, value := range values.Values {
+							if idx > 0 {
+								db.Statement.WriteByte(',')
+							}
+
+							db.Statement.WriteByte('(')
+							db.Statement.AddVar(db.Statement, value...)
+							db.Statement.WriteByte(')')
+						}
 					}
 
 					db.Statement.WriteString(";")
@@ -83,110 +120,437 @@ func Create(db *gorm.DB) {
 	if !db.DryRun && db.Error == nil {
 		db.RowsAffected = 0
 
+		var (
+			backfillable = backfillFields(db)
+			backfill     = len(backfillable) > 0
+			connPool     = db.Statement.ConnPool
+		)
+
+		// LAST_QUERY_ID() is per session, outside of a transaction the pool may run the select
+		// on another connection than the insert, so pin one for both
+		if pooler, ok := connPool.(connPooler); ok && backfill {
+			conn, err := pooler.Conn(db.Statement.Context)
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			defer conn.Close()
+			connPool = conn
+		}
+
+		if _, ok := returningFields(db); ok && backfill {
+			if err := checkChangeTracking(db, connPool); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+
 		// exec the merge/insert first
-		if result, err := db.Statement.ConnPool.ExecContext(db.Statement.Context, db.Statement.SQL.String(), db.Statement.Vars...); err == nil {
-			db.RowsAffected, _ = result.RowsAffected()
-		} else {
+		result, err := connPool.ExecContext(db.Statement.Context, db.Statement.SQL.String(), db.Statement.Vars...)
+		if err != nil {
 			_ = db.AddError(err)
+			return
 		}
+		db.RowsAffected, _ = result.RowsAffected()
 
-		// do another select on last inserted values to populate default values (e.g. ID)
-		// this relies on the result of SELECT * FROM CHANGES to align with the order of the VALUES in MERGE statement
-		if sch := db.Statement.Schema; sch != nil && len(db.Statement.Schema.FieldsWithDefaultDBValue) > 0 {
-			var (
-				fields = make([]*schema.Field, len(sch.FieldsWithDefaultDBValue))
-				values = make([]interface{}, len(sch.FieldsWithDefaultDBValue))
-			)
+		// do another select on last inserted values to populate default values (e.g. ID) or RETURNING columns
+		if backfill {
+			scanCreated(db, connPool, backfillable, columns, merged)
+		}
+	}
+}
 
-			db.Statement.SQL.Reset()
+// backfillFields fields to read back after the insert, the RETURNING columns or the fields with database defaults
+func backfillFields(db *gorm.DB) []*schema.Field {
+	sch := db.Statement.Schema
+	if sch == nil {
+		return nil
+	}
 
-			// write select
-			db.Statement.WriteString("SELECT ")
-			// populate fields
-			for idx, field := range sch.FieldsWithDefaultDBValue {
-				if idx > 0 {
-					db.Statement.WriteByte(',')
-				}
+	if fields, ok := returningFields(db); ok {
+		return fields
+	}
 
-				fields[idx] = field
-				db.Statement.WriteQuoted(field.DBName)
-			}
-			db.Statement.WriteString(" FROM ")
-			db.Statement.WriteQuoted(db.Statement.Table)
-			db.Statement.WriteString(" CHANGES(INFORMATION => APPEND_ONLY) BEFORE(statement=>LAST_QUERY_ID());")
-			rows, err := db.Statement.ConnPool.QueryContext(db.Statement.Context, db.Statement.SQL.String(), db.Statement.Vars...)
-			reflectIndex := 0
-			if err == nil {
-				defer rows.Close()
-
-				switch db.Statement.ReflectValue.Kind() {
-				case reflect.Slice, reflect.Array:
-					// the strategy here is to match the returned rows with INSERT only values
-					for rows.Next() {
-					BEGIN:
-						reflectValue := db.Statement.ReflectValue.Index(reflectIndex)
-						if reflect.Indirect(reflectValue).Kind() != reflect.Struct {
-							break
-						}
+	config := configOf(db)
+	fields := make([]*schema.Field, 0, len(sch.FieldsWithDefaultDBValue))
+	for _, field := range sch.FieldsWithDefaultDBValue {
+		// sequence backed IDs are assigned before the insert
+		if !config.usesSequence(field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// scanCreated select the rows written by the last statement with CHANGES and set their fields on the models they
+// were created from. CHANGES returns the rows in no particular order, they are matched with the models by primary key
+// when every model has one (e.g. sequence backed IDs in a MERGE), and otherwise by the values of the inserted columns,
+// see createdKeyFields. The models inserted by a MERGE are the ones whose database defaults are still zero.
+func scanCreated(db *gorm.DB, connPool gorm.ConnPool, fields []*schema.Field, columns []clause.Column, merged bool) {
+	var (
+		sch     = db.Statement.Schema
+		ctx     = db.Statement.Context
+		targets []reflect.Value
+		judges  []*schema.Field
+		keys    []*schema.Field
+		byKey   bool
+	)
+
+	if merged {
+		config := configOf(db)
+		for _, field := range sch.FieldsWithDefaultDBValue {
+			if !config.usesSequence(field) {
+				judges = append(judges, field)
+			}
+		}
+		if byKey = len(judges) == 0 && len(sch.PrimaryFields) > 0; byKey {
+			keys = sch.PrimaryFields
+		}
+	}
 
-						for idx, field := range fields {
-							fieldValue := field.ReflectValueOf(db.Statement.Context, reflectValue)
+	// skip where default are not zeros (non-insert in MERGE)
+	targets = createTargets(db, func(target reflect.Value) bool {
+		for _, field := range judges {
+			if _, isZero := targetValueOf(ctx, field, target); !isZero {
+				return false
+			}
+		}
+		return true
+	})
 
-							// skip where default are zeros (non-insert in MERGE)
-							if !fieldValue.IsZero() {
-								reflectIndex++
+	if len(targets) == 0 {
+		return
+	}
 
-								if reflectIndex >= db.Statement.ReflectValue.Len() {
-									return
-								}
+	if !byKey {
+		keys = createdKeyFields(db, fields, columns)
+	}
+	selected := withFields(fields, keys)
 
-								goto BEGIN
-							}
-							values[idx] = fieldValue.Addr().Interface()
-						}
+	index := make(map[string][]reflect.Value, len(targets))
+	for _, target := range targets {
+		key, err := createdKey(db, keys, target)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		index[key] = append(index[key], target)
+	}
 
-						if err := rows.Scan(values...); err != nil {
-							_ = db.AddError(err)
-						}
-					}
-				case reflect.Struct:
-					for idx, field := range fields {
-						values[idx] = field.ReflectValueOf(db.Statement.Context, db.Statement.ReflectValue).Addr().Interface()
-					}
+	db.Statement.SQL.Reset()
 
-					if rows.Next() {
-						db.AddError(rows.Scan(values...))
-					}
+	// write select
+	db.Statement.WriteString("SELECT ")
+	// populate fields
+	for idx, field := range selected {
+		if idx > 0 {
+			db.Statement.WriteByte(',')
+		}
+		db.Statement.WriteQuoted(field.DBName)
+	}
+	db.Statement.WriteString(" FROM ")
+	db.Statement.WriteQuoted(db.Statement.Table)
+	if byKey {
+		db.Statement.WriteString(" CHANGES(INFORMATION => DEFAULT)" + changesOfLastQuery + " WHERE METADATA$ACTION = 'INSERT';")
+	} else {
+		db.Statement.WriteString(" CHANGES(INFORMATION => APPEND_ONLY)" + changesOfLastQuery + ";")
+	}
+
+	result, err := connPool.QueryContext(ctx, db.Statement.SQL.String())
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	defer result.Close()
+
+	var (
+		rows    = scanRows(db, result)
+		scanned = make([]interface{}, len(selected))
+		values  = make([]interface{}, len(selected))
+	)
+	for idx := range values {
+		values[idx] = &scanned[idx]
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+
+		// the row as a model, to compare and set its values as the field types
+		row := reflect.New(sch.ModelType).Elem()
+		for idx, field := range selected {
+			if err := field.Set(ctx, row, scanned[idx]); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+
+		key, err := createdKey(db, keys, row)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		matched := index[key]
+		if len(matched) == 0 {
+			_ = db.AddError(fmt.Errorf("snowflake: a row created in %s matches none of the created %s", db.Statement.Table, sch.Name))
+			return
+		}
+		target := matched[0]
+		index[key] = matched[1:]
+
+		for _, field := range fields {
+			v, _ := field.ValueOf(ctx, row)
+			if err := setTargetValue(ctx, field, target, v); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	}
+	_ = db.AddError(rows.Err())
+}
+
+// createdKeyFields the inserted columns matching created rows with their models: those not read back, whose values
+// are stored as bound (not those converted by bind expressions or serializers, and no FLOAT or VARIANT). Models with
+// the same values for these columns are interchangeable, their rows are matched in any order.
+func createdKeyFields(db *gorm.DB, fields []*schema.Field, columns []clause.Column) []*schema.Field {
+	var (
+		sch   = db.Statement.Schema
+		types = configOf(db).Types
+		keys  []*schema.Field
+	)
+
+	backfilled := map[*schema.Field]bool{}
+	for _, field := range fields {
+		backfilled[field] = true
+	}
+
+	for _, column := range columns {
+		field := sch.LookUpField(column.Name)
+		if field == nil || backfilled[field] || field.Serializer != nil {
+			continue
+		}
+		if mapping, ok := types.lookUp(field); ok && mapping.BindExpr != "" {
+			continue
+		}
+
+		switch fieldType := indirectType(field.FieldType); fieldType.Kind() {
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			keys = append(keys, field)
+		case reflect.Slice:
+			if fieldType.Elem().Kind() == reflect.Uint8 && field.GORMDataType == schema.Bytes {
+				keys = append(keys, field)
+			}
+		case reflect.Struct:
+			if fieldType.ConvertibleTo(timeType) {
+				keys = append(keys, field)
+			}
+		}
+	}
+	return keys
+}
+
+// createdKey the values of keys in target as stored by snowflake, the values of maps are converted to the field types
+// first. Times are compared in the format of their column, in UTC unless they're a DATE or TIME, and at its precision.
+func createdKey(db *gorm.DB, keys []*schema.Field, target reflect.Value) (string, error) {
+	ctx := db.Statement.Context
+	if target.Kind() == reflect.Map {
+		model := reflect.New(db.Statement.Schema.ModelType).Elem()
+		for _, field := range keys {
+			if v, _ := targetValueOf(ctx, field, target); v != nil {
+				if err := field.Set(ctx, model, v); err != nil {
+					return "", err
 				}
-			} else {
-				db.AddError(err)
 			}
 		}
+		target = model
+	}
+
+	var key strings.Builder
+	for _, field := range keys {
+		rv := field.ReflectValueOf(ctx, target)
+		for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+
+		switch {
+		case rv.Kind() == reflect.Ptr:
+			key.WriteString("NULL")
+		case rv.Kind() == reflect.Struct && rv.Type().ConvertibleTo(timeType):
+			t := rv.Convert(timeType).Interface().(time.Time)
+			layout, _ := timeLayout(db, field)
+			if layout != dateLayout && layout != timeOfDayLayout {
+				t = t.UTC()
+			}
+			if field.Precision > 0 && field.Precision < 9 {
+				t = t.Truncate(time.Duration(math.Pow10(9 - field.Precision)))
+			}
+			key.WriteString(t.Format(layout))
+		default:
+			fmt.Fprintf(&key, "%q", fmt.Sprint(rv.Interface()))
+		}
+		key.WriteByte(0)
+	}
+	return key.String(), nil
+}
+
+// allocateSequenceIDs fetch a block of NEXTVAL from the sequence backing field and assign them
+// to every model (or map) without a primary key yet, so they are inserted explicitly
+func allocateSequenceIDs(db *gorm.DB, field *schema.Field) {
+	targets := createTargets(db, func(target reflect.Value) bool {
+		_, isZero := targetValueOf(db.Statement.Context, field, target)
+		return isZero
+	})
+
+	if len(targets) == 0 {
+		return
+	}
+
+	sequence := db.Statement.Quote(clause.Table{Name: sequenceName(db.Statement.Table, field.DBName)})
+	rows, err := db.Statement.ConnPool.QueryContext(
+		db.Statement.Context,
+		fmt.Sprintf("SELECT %s.NEXTVAL FROM TABLE(GENERATOR(ROWCOUNT => %d))", sequence, len(targets)),
+	)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	defer rows.Close()
+
+	for _, target := range targets {
+		if !rows.Next() {
+			_ = db.AddError(fmt.Errorf("snowflake: sequence %s returned fewer values than requested", sequence))
+			return
+		}
+
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+
+		if err := setTargetValue(db.Statement.Context, field, target, id); err != nil {
+			_ = db.AddError(err)
+			return
+		}
 	}
+	_ = db.AddError(rows.Err())
+}
+
+// createTargets the models being created, structs or map[string]interface{}, in order and
+// skipping nil pointers, that match the filter
+func createTargets(db *gorm.DB, filter func(target reflect.Value) bool) (targets []reflect.Value) {
+	collect := func(rv reflect.Value) {
+		for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+			rv = rv.Elem()
+		}
+
+		switch rv.Kind() {
+		case reflect.Struct, reflect.Map:
+			if filter(rv) {
+				targets = append(targets, rv)
+			}
+		}
+	}
+
+	switch rv := db.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			collect(rv.Index(i))
+		}
+	default:
+		collect(rv)
+	}
+	return
+}
+
+// targetValueOf the value of field in a struct or map target, maps are keyed by column or field name
+func targetValueOf(ctx context.Context, field *schema.Field, target reflect.Value) (interface{}, bool) {
+	if target.Kind() != reflect.Map {
+		return field.ValueOf(ctx, target)
+	}
+
+	for _, key := range []string{field.DBName, field.Name} {
+		if v := target.MapIndex(reflect.ValueOf(key)); v.IsValid() {
+			if v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+			if !v.IsValid() {
+				return nil, true
+			}
+			return v.Interface(), v.IsZero()
+		}
+	}
+	return nil, true
+}
+
+// setTargetValue set field in a struct or map target, maps keep the key they already use
+// for the field and default to the column name. Values set in maps are converted to the field's type
+// like in a model (e.g. the text of a NUMBER returned by gosnowflake into an int64 ID).
+func setTargetValue(ctx context.Context, field *schema.Field, target reflect.Value, value interface{}) error {
+	if target.Kind() != reflect.Map {
+		return field.Set(ctx, target, value)
+	}
+
+	key := reflect.ValueOf(field.DBName)
+	if name := reflect.ValueOf(field.Name); !target.MapIndex(key).IsValid() && target.MapIndex(name).IsValid() {
+		key = name
+	}
+
+	v := reflect.Zero(target.Type().Elem())
+	if value != nil {
+		model := reflect.New(field.Schema.ModelType).Elem()
+		if err := field.Set(ctx, model, value); err != nil {
+			return err
+		}
+
+		if v = field.ReflectValueOf(ctx, model); !v.Type().AssignableTo(target.Type().Elem()) {
+			if !v.Type().ConvertibleTo(target.Type().Elem()) {
+				return fmt.Errorf("snowflake: can't set %s of type %s in a %s", field.Name, v.Type(), target.Type())
+			}
+			v = v.Convert(target.Type().Elem())
+		}
+	}
+	target.SetMapIndex(key, v)
+	return nil
+}
+
+// insertsColumn if the column is part of the INSERT of MergeCreate, IDENTITY columns generate their own value
+func insertsColumn(db *gorm.DB, column string) bool {
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	return field == nil || !field.AutoIncrement || field.DBName != column || configOf(db).usesSequence(field)
 }
 
 func MergeCreate(db *gorm.DB, onConflict clause.OnConflict, values clause.Values) {
 	db.Statement.WriteString("MERGE INTO ")
 	db.Statement.WriteQuoted(db.Statement.Table)
-	db.Statement.WriteString(" USING (VALUES")
-	for idx, value := range values.Values {
-		if idx > 0 {
-			db.Statement.WriteByte(',')
-		}
+	if exprs := bindExprs(db, values.Columns); exprs != nil {
+		db.Statement.WriteString(" USING (")
+		writeSelectValues(db, values, exprs, true)
+		db.Statement.WriteString(") AS excluded ON ")
+	} else {
+		db.Statement.WriteString(" USING (VALUES")
+		for idx, value := range values.Values {
+			if idx > 0 {
+				db.Statement.WriteByte(',')
+			}
 
-		db.Statement.WriteByte('(')
-		db.Statement.AddVar(db.Statement, value...)
-		db.Statement.WriteByte(')')
-	}
+			db.Statement.WriteByte('(')
+			db.Statement.AddVar(db.Statement, value...)
+			db.Statement.WriteByte(')')
+		}
 
-	db.Statement.WriteString(") AS excluded (")
-	for idx, column := range values.Columns {
-		if idx > 0 {
-			db.Statement.WriteByte(',')
+		db.Statement.WriteString(") AS excluded (")
+		for idx, column := range values.Columns {
+			if idx > 0 {
+				db.Statement.WriteByte(',')
+			}
+			db.Statement.WriteQuoted(column.Name)
 		}
-		db.Statement.WriteQuoted(column.Name)
+		db.Statement.WriteString(") ON ")
 	}
-	db.Statement.WriteString(") ON ")
 
 	var where clause.Where
 	for _, field := range db.Statement.Schema.PrimaryFields {
@@ -206,7 +570,7 @@ func MergeCreate(db *gorm.DB, onConflict clause.OnConflict, values clause.Values
 
 	written := false
 	for _, column := range values.Columns {
-		if db.Statement.Schema.PrioritizedPrimaryField == nil || !db.Statement.Schema.PrioritizedPrimaryField.AutoIncrement || db.Statement.Schema.PrioritizedPrimaryField.DBName != column.Name {
+		if insertsColumn(db, column.Name) {
 			if written {
 				db.Statement.WriteByte(',')
 			}
@@ -219,7 +583,7 @@ func MergeCreate(db *gorm.DB, onConflict clause.OnConflict, values clause.Values
 
 	written = false
 	for _, column := range values.Columns {
-		if db.Statement.Schema.PrioritizedPrimaryField == nil || !db.Statement.Schema.PrioritizedPrimaryField.AutoIncrement || db.Statement.Schema.PrioritizedPrimaryField.DBName != column.Name {
+		if insertsColumn(db, column.Name) {
 			if written {
 				db.Statement.WriteByte(',')
 			}
@@ -0,0 +1,288 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type changeUser struct {
+	ID   int64 `gorm:"primaryKey;autoIncrement"`
+	Name string
+}
+
+// changesRow a row of changesTable, with the statement that inserted it
+type changesRow struct {
+	query int
+	id    int64
+	name  string
+}
+
+// changesTable emulate an IDENTITY table and its CHANGES: every insert is followed by a row committed by another
+// session, and CHANGES return the latest statements first, the rows of a statement in reverse order when shuffled
+type changesTable struct {
+	mu       sync.Mutex
+	queries  int
+	last     map[int]int
+	rows     []changesRow
+	shuffled bool
+}
+
+func (table *changesTable) handle(statement fakeStatement) (*fakeRows, error) {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+
+	if table.last == nil {
+		table.last = map[int]int{}
+	}
+	lastQuery := table.last[statement.conn]
+	table.queries++
+	table.last[statement.conn] = table.queries
+
+	switch {
+	case strings.HasPrefix(statement.query, "INSERT INTO"):
+		names := statement.args
+		if array, ok := statement.args[0].(*[]interface{}); ok {
+			names = *array
+		}
+		for _, name := range names {
+			table.rows = append(table.rows, changesRow{query: table.queries, id: int64(len(table.rows) + 1), name: name.(string)})
+		}
+		table.queries++
+		table.rows = append(table.rows, changesRow{query: table.queries, id: int64(len(table.rows) + 1), name: "other session"})
+		return &fakeRows{affected: int64(len(names))}, nil
+	case strings.Contains(statement.query, "CHANGES("):
+		bounded := strings.Contains(statement.query, "END(statement=>LAST_QUERY_ID())")
+
+		var changes []changesRow
+		for _, row := range table.rows {
+			if row.query == lastQuery || (!bounded && row.query > lastQuery) {
+				changes = append(changes, row)
+			}
+		}
+		sort.SliceStable(changes, func(i, j int) bool {
+			if table.shuffled && changes[i].query == changes[j].query {
+				return changes[i].id > changes[j].id
+			}
+			return changes[i].query > changes[j].query
+		})
+
+		columns := strings.Split(strings.TrimPrefix(statement.query[:strings.Index(statement.query, " FROM")], "SELECT "), ",")
+		rows := &fakeRows{columns: columns}
+		for _, row := range changes {
+			values := make([]driver.Value, len(columns))
+			for idx, column := range columns {
+				if column == "name" {
+					values[idx] = row.name
+				} else {
+					values[idx] = strconv.FormatInt(row.id, 10)
+				}
+			}
+			rows.values = append(rows.values, values)
+		}
+		return rows, nil
+	}
+	return nil, nil
+}
+
+func (table *changesTable) nameOf(id int64) string {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	for _, row := range table.rows {
+		if row.id == id {
+			return row.name
+		}
+	}
+	return ""
+}
+
+func TestCreateBackfillsIDsOfItsOwnRows(t *testing.T) {
+	table := &changesTable{}
+	db, d := openFake(t, Config{}, table.handle)
+
+	users := []changeUser{{Name: "alice"}, {Name: "bob"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"INSERT INTO change_users (name) VALUES (?),(?);",
+		"SELECT id,name FROM change_users CHANGES(INFORMATION => APPEND_ONLY) BEFORE(statement=>LAST_QUERY_ID()) END(statement=>LAST_QUERY_ID());",
+	)
+	for _, user := range users {
+		if name := table.nameOf(user.ID); name != user.Name {
+			t.Errorf("%s got the ID %d of %q", user.Name, user.ID, name)
+		}
+	}
+}
+
+func TestCreateMatchesShuffledChangesByValues(t *testing.T) {
+	table := &changesTable{shuffled: true}
+	db, _ := openFake(t, Config{}, table.handle)
+
+	users := []changeUser{{Name: "alice"}, {Name: "bob"}, {Name: "carol"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	list := []map[string]interface{}{{"name": "dave"}, {"name": "erin"}}
+	if err := db.Model(&changeUser{}).Create(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range users {
+		if name := table.nameOf(user.ID); name != user.Name {
+			t.Errorf("%s got the ID %d of %q", user.Name, user.ID, name)
+		}
+	}
+	for _, values := range list {
+		if id, ok := values["id"].(int64); !ok || table.nameOf(id) != values["name"] {
+			t.Errorf("maps: %s id = %#v", values["name"], values["id"])
+		}
+	}
+}
+
+func TestCreateBackfillsIDsConcurrently(t *testing.T) {
+	table := &changesTable{}
+	db, _ := openFake(t, Config{}, table.handle)
+
+	var (
+		wg     sync.WaitGroup
+		errs   = make(chan error, 8)
+		groups = make([][]changeUser, 8)
+	)
+	for g := range groups {
+		for i := 0; i < 5; i++ {
+			groups[g] = append(groups[g], changeUser{Name: fmt.Sprintf("user %d-%d", g, i)})
+		}
+
+		wg.Add(1)
+		go func(users []changeUser) {
+			defer wg.Done()
+			errs <- db.Create(&users).Error
+		}(groups[g])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, users := range groups {
+		for _, user := range users {
+			if name := table.nameOf(user.ID); name != user.Name {
+				t.Errorf("%s got the ID %d of %q", user.Name, user.ID, name)
+			}
+		}
+	}
+}
+
+func TestCreateBackfillsIDsOfEachTargetKind(t *testing.T) {
+	table := &changesTable{}
+	db, _ := openFake(t, Config{}, table.handle)
+
+	user := changeUser{Name: "alice"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if name := table.nameOf(user.ID); name != "alice" {
+		t.Errorf("struct got the ID %d of %q", user.ID, name)
+	}
+
+	users := []changeUser{{Name: "bob"}, {Name: "carol"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range users {
+		if name := table.nameOf(user.ID); name != user.Name {
+			t.Errorf("slice: %s got the ID %d of %q", user.Name, user.ID, name)
+		}
+	}
+
+	// gorm refuses nil entries before anything is inserted
+	pointers := []*changeUser{{Name: "dave"}, nil, {Name: "erin"}}
+	if err := db.Create(&pointers).Error; !errors.Is(err, gorm.ErrInvalidData) {
+		t.Errorf("nil entry: %v", err)
+	}
+	pointers = []*changeUser{{Name: "dave"}, {Name: "erin"}}
+	if err := db.Create(&pointers).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range pointers {
+		if name := table.nameOf(user.ID); name != user.Name {
+			t.Errorf("pointers: %s got the ID %d of %q", user.Name, user.ID, name)
+		}
+	}
+
+	values := map[string]interface{}{"name": "frank"}
+	if err := db.Model(&changeUser{}).Create(values).Error; err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := values["id"].(int64); !ok || table.nameOf(id) != "frank" {
+		t.Errorf("map id = %#v", values["id"])
+	}
+
+	list := []map[string]interface{}{{"name": "grace"}, {"name": "heidi"}}
+	if err := db.Model(&changeUser{}).Create(&list).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, values := range list {
+		if id, ok := values["id"].(int64); !ok || table.nameOf(id) != values["name"] {
+			t.Errorf("maps: %s id = %#v", values["name"], values["id"])
+		}
+	}
+}
+
+func TestCreateAssignsSequenceIDs(t *testing.T) {
+	db, d := openFake(t, Config{IDStrategy: IDSequence}, func(statement fakeStatement) (*fakeRows, error) {
+		if strings.Contains(statement.query, "NEXTVAL") {
+			return &fakeRows{columns: []string{"NEXTVAL"}, values: [][]driver.Value{{"7"}, {"8"}}}, nil
+		}
+		return nil, nil
+	})
+
+	users := []changeUser{{Name: "alice"}, {Name: "bob"}}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	record := map[string]interface{}{"name": "carol"}
+	if err := db.Model(&changeUser{}).Create(record).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	assertQueries(t, d.queries(),
+		"SELECT change_users_id_seq.NEXTVAL FROM TABLE(GENERATOR(ROWCOUNT => 2))",
+		"INSERT INTO change_users (name,id) VALUES (?,?),(?,?);",
+		"SELECT change_users_id_seq.NEXTVAL FROM TABLE(GENERATOR(ROWCOUNT => 1))",
+		"INSERT INTO change_users (id,name) VALUES (?,?);",
+	)
+	if users[0].ID != 7 || users[1].ID != 8 || record["id"] != int64(7) {
+		t.Errorf("IDs = %d, %d, %v", users[0].ID, users[1].ID, record["id"])
+	}
+	if args := d.statements[1].args; args[1] != int64(7) || args[3] != int64(8) {
+		t.Errorf("args = %v", args)
+	}
+}
+
+func TestCreateWithTooFewSequenceIDs(t *testing.T) {
+	db, d := openFake(t, Config{IDStrategy: IDSequence}, func(statement fakeStatement) (*fakeRows, error) {
+		if strings.Contains(statement.query, "NEXTVAL") {
+			return fakeRow([]string{"NEXTVAL"}, "7"), nil
+		}
+		return nil, nil
+	})
+
+	users := []changeUser{{Name: "alice"}, {Name: "bob"}}
+	err := db.Create(&users).Error
+	if err == nil || err.Error() != "snowflake: sequence change_users_id_seq returned fewer values than requested" {
+		t.Errorf("error = %v", err)
+	}
+	assertQueries(t, d.queries(), "SELECT change_users_id_seq.NEXTVAL FROM TABLE(GENERATOR(ROWCOUNT => 2))")
+}
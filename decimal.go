@@ -0,0 +1,238 @@
+package snowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// maxScale the maximum scale of a NUMBER
+const maxScale = 37
+
+func init() {
+	schema.RegisterSerializer("decimal", DecimalSerializer{})
+}
+
+// Decimal an exact NUMBER(p,s) value, p and s are the precision and scale tags of the field,
+// e.g. `gorm:"precision:12;scale:2"` (NUMBER(38,0) without them). The zero Decimal is NULL.
+type Decimal struct {
+	rat *big.Rat
+}
+
+// NewDecimal the decimal of s, e.g. "-12.50" or "1e-3"
+func NewDecimal(s string) (Decimal, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Decimal{}, fmt.Errorf("snowflake: invalid decimal %q", s)
+	}
+	return Decimal{rat: rat}, nil
+}
+
+// DecimalFromRat the decimal of r, NULL when nil
+func DecimalFromRat(r *big.Rat) Decimal {
+	if r == nil {
+		return Decimal{}
+	}
+	return Decimal{rat: new(big.Rat).Set(r)}
+}
+
+// DecimalFromInt the decimal of i, NULL when nil
+func DecimalFromInt(i *big.Int) Decimal {
+	if i == nil {
+		return Decimal{}
+	}
+	return Decimal{rat: new(big.Rat).SetInt(i)}
+}
+
+// IsNull if the decimal is NULL
+func (d Decimal) IsNull() bool {
+	return d.rat == nil
+}
+
+// Rat the value of the decimal, nil when NULL
+func (d Decimal) Rat() *big.Rat {
+	if d.rat == nil {
+		return nil
+	}
+	return new(big.Rat).Set(d.rat)
+}
+
+// String the decimal digits, rounded to the maximum scale of a NUMBER when they don't terminate
+func (d Decimal) String() string {
+	if d.rat == nil {
+		return "NULL"
+	}
+	return decimalString(d.rat)
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d.rat == nil {
+		return []byte("null"), nil
+	}
+	return []byte(decimalString(d.rat)), nil
+}
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		d.rat = nil
+		return nil
+	}
+
+	value, err := NewDecimal(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*d = value
+	return nil
+}
+
+// Value the decimal as text, Snowflake converts it to the NUMBER of the column without going through a float
+func (d Decimal) Value() (driver.Value, error) {
+	if d.rat == nil {
+		return nil, nil
+	}
+	return decimalString(d.rat), nil
+}
+
+// Scan the NUMBER, gosnowflake returns its digits as text (or as big numbers with higher precision)
+func (d *Decimal) Scan(src interface{}) error {
+	var text string
+	switch data := src.(type) {
+	case nil:
+		d.rat = nil
+		return nil
+	case string:
+		text = data
+	case []byte:
+		text = string(data)
+	case int64:
+		d.rat = new(big.Rat).SetInt64(data)
+		return nil
+	case float64:
+		text = strconv.FormatFloat(data, 'g', -1, 64)
+	case *big.Int:
+		d.rat = new(big.Rat).SetInt(data)
+		return nil
+	case *big.Float:
+		// the shortest digits identifying the float, the scaled NUMBER they came from
+		text = data.Text('g', -1)
+	case *big.Rat:
+		d.rat = new(big.Rat).Set(data)
+		return nil
+	default:
+		return fmt.Errorf("snowflake: can't scan %T into Decimal", src)
+	}
+
+	value, err := NewDecimal(text)
+	if err != nil {
+		return err
+	}
+	*d = value
+	return nil
+}
+
+func (Decimal) GormDataType() string {
+	return "decimal"
+}
+
+// DecimalSerializer store *big.Int and *big.Rat fields (or their values) as NUMBER, e.g.
+// `gorm:"serializer:decimal;precision:38;scale:10"`
+type DecimalSerializer struct{}
+
+func (DecimalSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var d Decimal
+	if err := d.Scan(dbValue); err != nil {
+		return err
+	}
+
+	fieldValue := reflect.New(field.FieldType)
+	if d.rat != nil {
+		switch target := fieldValue.Interface().(type) {
+		case **big.Rat:
+			*target = d.Rat()
+		case *big.Rat:
+			target.Set(d.rat)
+		case **big.Int:
+			if !d.rat.IsInt() {
+				return fmt.Errorf("snowflake: can't scan %s into %s, it isn't an integer", d, field.Name)
+			}
+			*target = new(big.Int).Set(d.rat.Num())
+		case *big.Int:
+			if !d.rat.IsInt() {
+				return fmt.Errorf("snowflake: can't scan %s into %s, it isn't an integer", d, field.Name)
+			}
+			target.Set(d.rat.Num())
+		default:
+			return fmt.Errorf("snowflake: unsupported decimal field %s of type %s", field.Name, field.FieldType)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+func (DecimalSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	switch value := fieldValue.(type) {
+	case *big.Rat:
+		return DecimalFromRat(value).Value()
+	case big.Rat:
+		return DecimalFromRat(&value).Value()
+	case *big.Int:
+		return DecimalFromInt(value).Value()
+	case big.Int:
+		return DecimalFromInt(&value).Value()
+	}
+	return nil, fmt.Errorf("snowflake: unsupported decimal field %s of type %s", field.Name, field.FieldType)
+}
+
+// isDecimalSerialized if the field is stored as NUMBER by the decimal serializer
+func isDecimalSerialized(field *schema.Field) bool {
+	return strings.EqualFold(field.TagSettings["SERIALIZER"], "decimal")
+}
+
+// numberType the NUMBER(p,s) of the precision and scale of field
+func numberType(field *schema.Field) string {
+	precision := field.Precision
+	if precision <= 0 {
+		precision = 38
+	}
+	return fmt.Sprintf("NUMBER(%d,%d)", precision, field.Scale)
+}
+
+// decimalString the digits of r, exact when r is a terminating decimal
+func decimalString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	// a fraction terminates when its denominator only has the factors 2 and 5, with as many digits as the most of them
+	var (
+		denom = new(big.Int).Set(r.Denom())
+		scale = 0
+		rem   = new(big.Int)
+	)
+	for _, factor := range []*big.Int{big.NewInt(2), big.NewInt(5)} {
+		count := 0
+		for {
+			quo, mod := new(big.Int).QuoRem(denom, factor, rem)
+			if mod.Sign() != 0 {
+				break
+			}
+			denom, count = quo, count+1
+		}
+		if count > scale {
+			scale = count
+		}
+	}
+
+	if denom.Cmp(big.NewInt(1)) != 0 || scale > maxScale {
+		scale = maxScale
+	}
+	return r.FloatString(scale)
+}
@@ -0,0 +1,265 @@
+package snowflake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/snowflakedb/gosnowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeRows the result of a statement run by a fakeDriver
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	// affected rows of an Exec, the number of values when 0
+	affected int64
+}
+
+// fakeStatement a statement run by a fakeDriver
+type fakeStatement struct {
+	conn  int
+	query string
+	args  []interface{}
+}
+
+// fakeDriver a database/sql driver recording its statements and answering them with handle,
+// statements without an answer return no rows
+type fakeDriver struct {
+	mu         sync.Mutex
+	handle     func(statement fakeStatement) (*fakeRows, error)
+	statements []fakeStatement
+	conns      int
+	closed     int
+	// connectErr fails new connections when set
+	connectErr error
+}
+
+// openFake open gorm on a fakeDriver
+func openFake(t *testing.T, config Config, handle func(statement fakeStatement) (*fakeRows, error)) (*gorm.DB, *fakeDriver) {
+	t.Helper()
+	d := &fakeDriver{handle: handle}
+	pool := sql.OpenDB(d)
+	t.Cleanup(func() { pool.Close() })
+
+	config.Conn = pool
+	db, err := gorm.Open(New(config), &gorm.Config{Logger: logger.Discard, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+// queries the statements run so far
+func (d *fakeDriver) queries() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queries := make([]string, len(d.statements))
+	for idx, statement := range d.statements {
+		queries[idx] = statement.query
+	}
+	return queries
+}
+
+// closedConns the driver connections closed so far, the pool only closes discarded connections in these tests
+func (d *fakeDriver) closedConns() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+func (d *fakeDriver) run(conn int, query string, named []driver.NamedValue) (*fakeRows, error) {
+	args := make([]interface{}, len(named))
+	for idx, arg := range named {
+		args[idx] = arg.Value
+	}
+
+	statement := fakeStatement{conn: conn, query: query, args: args}
+	d.mu.Lock()
+	d.statements = append(d.statements, statement)
+	handle := d.handle
+	d.mu.Unlock()
+
+	if handle == nil {
+		return &fakeRows{}, nil
+	}
+	rows, err := handle(statement)
+	if rows == nil && err == nil {
+		rows = &fakeRows{}
+	}
+	return rows, err
+}
+
+func (d *fakeDriver) Connect(context.Context) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.connectErr != nil {
+		return nil, d.connectErr
+	}
+	d.conns++
+	return &fakeConn{driver: d, id: d.conns}, nil
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return d.Connect(context.Background())
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+	id     int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	c.driver.closed++
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if _, err := c.driver.run(c.id, "BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return &fakeTx{conn: c}, nil
+}
+
+// CheckNamedValue bind slices as arrays like gosnowflake, convert anything else as database/sql does
+func (c *fakeConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.(driver.Valuer); !ok && nv.Value != nil {
+		rt := reflect.TypeOf(nv.Value)
+		if rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		if rt.Kind() == reflect.Slice && rt.Elem().Kind() != reflect.Uint8 {
+			return nil
+		}
+	}
+	return driver.ErrSkip
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rows, err := c.driver.run(c.id, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if rows.affected == 0 {
+		rows.affected = int64(len(rows.values))
+	}
+	return driver.RowsAffected(rows.affected), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rows, err := c.driver.run(c.id, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// like gosnowflake, send the query ID to the channel of gosnowflake.WithQueryIDChan, fake-query-<n> for the n-th
+	// statement run
+	if queryIDs, ok := ctx.Value(queryIDKey).(chan<- string); ok && queryIDs != nil {
+		c.driver.mu.Lock()
+		id := fmt.Sprintf("fake-query-%d", len(c.driver.statements))
+		c.driver.mu.Unlock()
+		select {
+		case queryIDs <- id:
+		default:
+		}
+	}
+	return &fakeDriverRows{rows: rows}, nil
+}
+
+// queryIDKey the unexported context key of gosnowflake.WithQueryIDChan
+var queryIDKey = func() interface{} {
+	key := reflect.ValueOf(gosnowflake.WithQueryIDChan(context.Background(), nil)).Elem().FieldByName("key")
+	return reflect.NewAt(key.Type(), unsafe.Pointer(key.UnsafeAddr())).Elem().Interface()
+}()
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (tx *fakeTx) Commit() error {
+	_, err := tx.conn.driver.run(tx.conn.id, "COMMIT", nil)
+	return err
+}
+
+func (tx *fakeTx) Rollback() error {
+	_, err := tx.conn.driver.run(tx.conn.id, "ROLLBACK", nil)
+	return err
+}
+
+type fakeDriverRows struct {
+	rows *fakeRows
+	pos  int
+}
+
+func (r *fakeDriverRows) Columns() []string {
+	return r.rows.columns
+}
+
+func (r *fakeDriverRows) Close() error {
+	return nil
+}
+
+func (r *fakeDriverRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.pos])
+	r.pos++
+	return nil
+}
+
+// fakeRow a single row result
+func fakeRow(columns []string, values ...driver.Value) *fakeRows {
+	return &fakeRows{columns: columns, values: [][]driver.Value{values}}
+}
+
+// assertQueries check the statements run, in order, each matching its expected prefix
+func assertQueries(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("queries = %s\nwant %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+	for idx := range want {
+		if !strings.HasPrefix(got[idx], want[idx]) {
+			t.Fatalf("query %d = %q, want %q", idx, got[idx], want[idx])
+		}
+	}
+}
+
+func TestFakeDriver(t *testing.T) {
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		return fakeRow([]string{"N"}, fmt.Sprint(statement.args[0])), nil
+	})
+
+	var n int
+	if err := db.Raw("SELECT ?", 1).Scan(&n).Error; err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	assertQueries(t, d.queries(), "SELECT ?")
+}
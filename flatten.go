@@ -0,0 +1,108 @@
+package snowflake
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FlattenExpr a LATERAL FLATTEN of a semi-structured column, joined to the table of the query
+type FlattenExpr struct {
+	input     interface{}
+	alias     string
+	outer     bool
+	recursive bool
+	mode      string
+}
+
+// Flatten explode the elements of input (a column name or a Path) as rows aliased by alias, e.g.
+//
+//	items := snowflake.Flatten("payload", "f").Outer()
+//	db.Table("events").
+//		Select("events.id, ? AS sku, ? AS position", items.ValuePath("sku").As("STRING"), items.Index()).
+//		Scopes(items.Join).
+//		Scan(&rows)
+//
+// Each row has the FLATTEN columns SEQ, KEY, PATH, INDEX, VALUE and THIS, see Column.
+func Flatten(input interface{}, alias string) FlattenExpr {
+	return FlattenExpr{input: input, alias: alias}
+}
+
+// Outer keep the rows whose input is empty or NULL, with NULL flattened columns (OUTER => TRUE)
+func (f FlattenExpr) Outer() FlattenExpr {
+	f.outer = true
+	return f
+}
+
+// Recursive flatten nested elements too (RECURSIVE => TRUE)
+func (f FlattenExpr) Recursive() FlattenExpr {
+	f.recursive = true
+	return f
+}
+
+// Mode flatten only OBJECT, ARRAY or BOTH (the default)
+func (f FlattenExpr) Mode(mode string) FlattenExpr {
+	f.mode = strings.ToUpper(mode)
+	return f
+}
+
+// Join scope adding the lateral join to the query, e.g. db.Scopes(items.Join)
+func (f FlattenExpr) Join(db *gorm.DB) *gorm.DB {
+	return db.Joins("?", f)
+}
+
+// Column a column of the flattened rows (seq, key, path, index, value or this)
+func (f FlattenExpr) Column(name string) clause.Column {
+	return clause.Column{Table: f.alias, Name: name}
+}
+
+// Value the flattened element
+func (f FlattenExpr) Value() clause.Column {
+	return f.Column("value")
+}
+
+// Index the index of the element in an array, NULL for objects
+func (f FlattenExpr) Index() clause.Column {
+	return f.Column("index")
+}
+
+// Key the key of the element in an object, NULL for arrays
+func (f FlattenExpr) Key() clause.Column {
+	return f.Column("key")
+}
+
+// ValuePath a path into the flattened element, e.g. f.value:sku
+func (f FlattenExpr) ValuePath(elements ...interface{}) PathExpr {
+	return Path(f.alias+".value", elements...)
+}
+
+func (f FlattenExpr) Build(builder clause.Builder) {
+	switch f.mode {
+	case "", "OBJECT", "ARRAY", "BOTH":
+	default:
+		if stmt, ok := builder.(*gorm.Statement); ok {
+			_ = stmt.AddError(fmt.Errorf("snowflake: invalid flatten mode %q", f.mode))
+		}
+		return
+	}
+
+	builder.WriteString(", LATERAL FLATTEN(INPUT => ")
+	if input, ok := f.input.(string); ok {
+		builder.WriteQuoted(input)
+	} else {
+		builder.AddVar(builder, f.input)
+	}
+	if f.outer {
+		builder.WriteString(", OUTER => TRUE")
+	}
+	if f.recursive {
+		builder.WriteString(", RECURSIVE => TRUE")
+	}
+	if f.mode != "" {
+		builder.WriteString(", MODE => '" + f.mode + "'")
+	}
+	builder.WriteString(") ")
+	builder.WriteQuoted(f.alias)
+}
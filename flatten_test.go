@@ -0,0 +1,64 @@
+package snowflake
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestFlattenJoin(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+
+	for _, test := range []struct {
+		flatten FlattenExpr
+		want    string
+	}{
+		{Flatten("payload", "f"), "LATERAL FLATTEN(INPUT => payload) f"},
+		{Flatten("payload", "f").Outer(), "LATERAL FLATTEN(INPUT => payload, OUTER => TRUE) f"},
+		{Flatten("payload", "f").Recursive(), "LATERAL FLATTEN(INPUT => payload, RECURSIVE => TRUE) f"},
+		{Flatten("payload", "f").Mode("array"), "LATERAL FLATTEN(INPUT => payload, MODE => 'ARRAY') f"},
+		{Flatten("payload", "f").Mode("OBJECT"), "LATERAL FLATTEN(INPUT => payload, MODE => 'OBJECT') f"},
+		{Flatten("payload", "f").Outer().Recursive().Mode("both"), "LATERAL FLATTEN(INPUT => payload, OUTER => TRUE, RECURSIVE => TRUE, MODE => 'BOTH') f"},
+		{Flatten(Path("payload", "order items"), "f"), "LATERAL FLATTEN(INPUT => payload['order items']) f"},
+	} {
+		var rows []map[string]interface{}
+		stmt := dry.Table("events").Scopes(test.flatten.Join).Find(&rows).Statement
+		if got, want := stmt.SQL.String(), "SELECT * FROM events , "+test.want; got != want {
+			t.Errorf("\n got %s\nwant %s", got, want)
+		}
+	}
+}
+
+func TestFlattenColumns(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+	items := Flatten("payload", "f").Outer()
+
+	var rows []map[string]interface{}
+	stmt := dry.Table("events").
+		Select("events.id, ? AS sku, ? AS position, ?, ?", items.ValuePath("sku").As("STRING"), items.Index(), items.Key(), items.Value()).
+		Scopes(items.Join).
+		Where("? > ?", items.ValuePath("qty").As("NUMBER"), 1).
+		Find(&rows).Statement
+	want := "SELECT events.id, f.value:sku::STRING AS sku, f.index AS position, f.key, f.value FROM events , LATERAL FLATTEN(INPUT => payload, OUTER => TRUE) f WHERE f.value:qty::NUMBER > ?"
+	if got := stmt.SQL.String(); got != want {
+		t.Errorf("\n got %s\nwant %s", got, want)
+	}
+	if !reflect.DeepEqual(stmt.Vars, []interface{}{1}) {
+		t.Errorf("vars = %#v", stmt.Vars)
+	}
+}
+
+func TestFlattenRefusesInvalidModes(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+
+	var rows []map[string]interface{}
+	err := dry.Table("events").Scopes(Flatten("payload", "f").Mode("ARRAY') f, secrets s --").Join).Find(&rows).Error
+	if err == nil || !strings.Contains(err.Error(), "invalid flatten mode") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,903 @@
+package snowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SpatialFormat representation a spatial value is kept in
+type SpatialFormat int
+
+const (
+	// WKT well-known text, EWKT (SRID=4326;POINT(1 2)) included
+	WKT SpatialFormat = iota + 1
+	// WKB well-known binary, EWKB included
+	WKB
+	// GeoJSON geometry object
+	GeoJSON
+)
+
+// spatial a GEOGRAPHY or GEOMETRY value in the format it was created or scanned with
+type spatial struct {
+	format SpatialFormat
+	data   []byte
+}
+
+// Geography a GEOGRAPHY value, bound with TO_GEOGRAPHY
+type Geography struct {
+	spatial
+}
+
+// Geometry a GEOMETRY value, bound with TO_GEOMETRY
+type Geometry struct {
+	spatial
+}
+
+// GeographyFromWKT the GEOGRAPHY of a WKT or EWKT text, e.g. POINT(-122.35 37.55)
+func GeographyFromWKT(wkt string) Geography {
+	return Geography{spatial{format: WKT, data: []byte(wkt)}}
+}
+
+// GeographyFromWKB the GEOGRAPHY of a WKB or EWKB value
+func GeographyFromWKB(wkb []byte) Geography {
+	return Geography{spatial{format: WKB, data: wkb}}
+}
+
+// GeographyFromGeoJSON the GEOGRAPHY of a GeoJSON geometry
+func GeographyFromGeoJSON(geojson string) Geography {
+	return Geography{spatial{format: GeoJSON, data: []byte(geojson)}}
+}
+
+// GeometryFromWKT the GEOMETRY of a WKT or EWKT text
+func GeometryFromWKT(wkt string) Geometry {
+	return Geometry{spatial{format: WKT, data: []byte(wkt)}}
+}
+
+// GeometryFromWKB the GEOMETRY of a WKB or EWKB value
+func GeometryFromWKB(wkb []byte) Geometry {
+	return Geometry{spatial{format: WKB, data: wkb}}
+}
+
+// GeometryFromGeoJSON the GEOMETRY of a GeoJSON geometry
+func GeometryFromGeoJSON(geojson string) Geometry {
+	return Geometry{spatial{format: GeoJSON, data: []byte(geojson)}}
+}
+
+// IsNull if the value is NULL
+func (s spatial) IsNull() bool {
+	return s.format == 0
+}
+
+// Format the representation the value is kept in
+func (s spatial) Format() SpatialFormat {
+	return s.format
+}
+
+// WKT the value as well-known text, without SRID
+func (s spatial) WKT() (string, error) {
+	if s.format == WKT {
+		if idx := bytes.IndexByte(s.data, ';'); idx >= 0 && bytes.HasPrefix(bytes.ToUpper(s.data), []byte("SRID=")) {
+			return strings.TrimSpace(string(s.data[idx+1:])), nil
+		}
+		return string(s.data), nil
+	}
+
+	g, err := s.shape()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	g.writeWKT(&b)
+	return b.String(), nil
+}
+
+// WKB the value as little endian well-known binary, without SRID
+func (s spatial) WKB() ([]byte, error) {
+	g, err := s.shape()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	g.writeWKB(&b)
+	return b.Bytes(), nil
+}
+
+// GeoJSON the value as a GeoJSON geometry
+func (s spatial) GeoJSON() (string, error) {
+	if s.format == GeoJSON {
+		return string(s.data), nil
+	}
+
+	g, err := s.shape()
+	if err != nil {
+		return "", err
+	}
+	if g.measured() {
+		return "", errors.New("snowflake: GeoJSON positions can't hold M coordinates")
+	}
+	data, err := json.Marshal(g.geoJSON())
+	return string(data), err
+}
+
+func (s spatial) shape() (*shape, error) {
+	switch s.format {
+	case WKT:
+		return parseWKT(string(s.data))
+	case WKB:
+		r := &wkbReader{data: s.data}
+		return r.read()
+	case GeoJSON:
+		return parseGeoJSON(s.data)
+	}
+	return nil, errors.New("snowflake: NULL spatial value")
+}
+
+func (s spatial) value() (driver.Value, error) {
+	switch s.format {
+	case 0:
+		return nil, nil
+	case WKB:
+		return s.data, nil
+	}
+	return string(s.data), nil
+}
+
+// scan a value in the GEOGRAPHY_OUTPUT_FORMAT/GEOMETRY_OUTPUT_FORMAT of the session (GeoJSON by default)
+func (s *spatial) scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*s = spatial{}
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		if len(v) > 0 && (v[0] == 0 || v[0] == 1) {
+			*s = spatial{format: WKB, data: append([]byte(nil), v...)}
+			return nil
+		}
+		data = append([]byte(nil), v...)
+	default:
+		return fmt.Errorf("snowflake: can't scan %T into a spatial value", src)
+	}
+
+	text := bytes.TrimSpace(data)
+	switch {
+	case len(text) > 0 && text[0] == '{':
+		*s = spatial{format: GeoJSON, data: text}
+	case isHex(text):
+		wkb := make([]byte, hex.DecodedLen(len(text)))
+		if _, err := hex.Decode(wkb, text); err != nil {
+			return err
+		}
+		*s = spatial{format: WKB, data: wkb}
+	default:
+		*s = spatial{format: WKT, data: text}
+	}
+	return nil
+}
+
+func (g Geography) Value() (driver.Value, error) {
+	return g.value()
+}
+
+func (g *Geography) Scan(src interface{}) error {
+	return g.scan(src)
+}
+
+func (Geography) GormDataType() string {
+	return "geography"
+}
+
+func (g Geography) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	return spatialExpr("TO_GEOGRAPHY(?)", g)
+}
+
+func (g Geometry) Value() (driver.Value, error) {
+	return g.value()
+}
+
+func (g *Geometry) Scan(src interface{}) error {
+	return g.scan(src)
+}
+
+func (Geometry) GormDataType() string {
+	return "geometry"
+}
+
+func (g Geometry) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	return spatialExpr("TO_GEOMETRY(?)", g)
+}
+
+func spatialExpr(sql string, valuer driver.Valuer) clause.Expr {
+	value, _ := valuer.Value()
+	if value == nil {
+		return clause.Expr{SQL: "NULL"}
+	}
+	return clause.Expr{SQL: sql, Vars: []interface{}{value}}
+}
+
+// STDWithin if a and b are within distance (meters for GEOGRAPHY), arguments are column names, spatial values
+// or expressions, e.g. db.Where(snowflake.STDWithin("location", point, 500))
+func STDWithin(a, b interface{}, distance float64) clause.Expression {
+	return clause.Expr{SQL: "ST_DWITHIN(?, ?, ?)", Vars: []interface{}{spatialArg(a), spatialArg(b), distance}}
+}
+
+// STIntersects if a and b intersect
+func STIntersects(a, b interface{}) clause.Expression {
+	return clause.Expr{SQL: "ST_INTERSECTS(?, ?)", Vars: []interface{}{spatialArg(a), spatialArg(b)}}
+}
+
+// STDistance the distance between a and b, e.g. db.Clauses(clause.OrderBy{Expression: snowflake.STDistance("location", point)})
+func STDistance(a, b interface{}) clause.Expression {
+	return clause.Expr{SQL: "ST_DISTANCE(?, ?)", Vars: []interface{}{spatialArg(a), spatialArg(b)}}
+}
+
+// spatialArg column names are quoted, anything else is bound
+func spatialArg(v interface{}) interface{} {
+	if name, ok := v.(string); ok {
+		return clause.Column{Name: name}
+	}
+	return v
+}
+
+func isHex(data []byte) bool {
+	if len(data) == 0 || len(data)%2 != 0 {
+		return false
+	}
+	for _, c := range data {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// shape a decoded geometry, coordinates nest like GeoJSON: a position is []float64 and lists are []interface{}.
+// dim is the Z, M or ZM tag of the coordinates in WKT or WKB, empty when they're only counted (GeoJSON).
+type shape struct {
+	kind       string
+	dim        string
+	coords     interface{}
+	geometries []*shape
+}
+
+// wktKinds WKT names by GeoJSON type, with the nesting depth of their coordinates and their WKB code
+var wktKinds = map[string]struct {
+	name  string
+	depth int
+	code  uint32
+}{
+	"Point":              {"POINT", 0, 1},
+	"LineString":         {"LINESTRING", 1, 2},
+	"Polygon":            {"POLYGON", 2, 3},
+	"MultiPoint":         {"MULTIPOINT", 1, 4},
+	"MultiLineString":    {"MULTILINESTRING", 2, 5},
+	"MultiPolygon":       {"MULTIPOLYGON", 3, 6},
+	"GeometryCollection": {"GEOMETRYCOLLECTION", 0, 7},
+}
+
+func kindOf(name string) (string, bool) {
+	for kind, k := range wktKinds {
+		if strings.EqualFold(k.name, name) || strings.EqualFold(kind, name) {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+func (g *shape) empty() bool {
+	if g.kind == "GeometryCollection" {
+		return len(g.geometries) == 0
+	}
+	switch c := g.coords.(type) {
+	case []float64:
+		return len(c) == 0
+	case []interface{}:
+		return len(c) == 0
+	}
+	return true
+}
+
+func (g *shape) dims() int {
+	if g.kind == "GeometryCollection" {
+		for _, child := range g.geometries {
+			if d := child.dims(); d > 0 {
+				return d
+			}
+		}
+		return 2
+	}
+
+	c := g.coords
+	for {
+		switch v := c.(type) {
+		case []float64:
+			return len(v)
+		case []interface{}:
+			if len(v) == 0 {
+				return 2
+			}
+			c = v[0]
+		default:
+			return 2
+		}
+	}
+}
+
+// dimension the Z, M or ZM tag of the coordinates, counted when untagged: 3 for Z and 4 for ZM
+func (g *shape) dimension() string {
+	if g.dim != "" {
+		return g.dim
+	}
+	if g.kind == "GeometryCollection" {
+		for _, child := range g.geometries {
+			if !child.empty() {
+				return child.dimension()
+			}
+		}
+		return ""
+	}
+
+	switch g.dims() {
+	case 3:
+		return "Z"
+	case 4:
+		return "ZM"
+	}
+	return ""
+}
+
+// measured if the geometry or one of its children has M coordinates
+func (g *shape) measured() bool {
+	for _, child := range g.geometries {
+		if child.measured() {
+			return true
+		}
+	}
+	return strings.HasSuffix(g.dimension(), "M")
+}
+
+func (g *shape) writeWKT(b *strings.Builder) {
+	b.WriteString(wktKinds[g.kind].name)
+	if g.empty() {
+		b.WriteString(" EMPTY")
+		return
+	}
+	if dim := g.dimension(); dim != "" {
+		b.WriteString(" " + dim)
+	}
+
+	if g.kind == "GeometryCollection" {
+		b.WriteByte('(')
+		for idx, child := range g.geometries {
+			if idx > 0 {
+				b.WriteByte(',')
+			}
+			child.writeWKT(b)
+		}
+		b.WriteByte(')')
+		return
+	}
+
+	if position, ok := g.coords.([]float64); ok {
+		b.WriteByte('(')
+		writeWKTPosition(b, position)
+		b.WriteByte(')')
+		return
+	}
+	writeWKTCoords(b, g.coords)
+}
+
+func writeWKTCoords(b *strings.Builder, coords interface{}) {
+	switch c := coords.(type) {
+	case []float64:
+		writeWKTPosition(b, c)
+	case []interface{}:
+		b.WriteByte('(')
+		for idx, child := range c {
+			if idx > 0 {
+				b.WriteByte(',')
+			}
+			writeWKTCoords(b, child)
+		}
+		b.WriteByte(')')
+	}
+}
+
+func writeWKTPosition(b *strings.Builder, position []float64) {
+	for idx, v := range position {
+		if idx > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+}
+
+func (g *shape) geoJSON() map[string]interface{} {
+	if g.kind == "GeometryCollection" {
+		geometries := make([]interface{}, len(g.geometries))
+		for idx, child := range g.geometries {
+			geometries[idx] = child.geoJSON()
+		}
+		return map[string]interface{}{"type": g.kind, "geometries": geometries}
+	}
+
+	coords := g.coords
+	if coords == nil {
+		coords = []interface{}{}
+	}
+	return map[string]interface{}{"type": g.kind, "coordinates": coords}
+}
+
+func (g *shape) writeWKB(b *bytes.Buffer) {
+	code := wktKinds[g.kind].code
+	dims := g.dims()
+	switch g.dimension() {
+	case "Z":
+		code += 1000
+	case "M":
+		code += 2000
+	case "ZM":
+		code += 3000
+	}
+	b.WriteByte(1)
+	_ = binary.Write(b, binary.LittleEndian, code)
+
+	switch g.kind {
+	case "Point":
+		position, _ := g.coords.([]float64)
+		if len(position) == 0 {
+			// empty point
+			position = make([]float64, dims)
+			for idx := range position {
+				position[idx] = math.NaN()
+			}
+		}
+		_ = binary.Write(b, binary.LittleEndian, position)
+	case "GeometryCollection":
+		_ = binary.Write(b, binary.LittleEndian, uint32(len(g.geometries)))
+		for _, child := range g.geometries {
+			child.writeWKB(b)
+		}
+	case "MultiPoint", "MultiLineString", "MultiPolygon":
+		children, _ := g.coords.([]interface{})
+		kind := strings.TrimPrefix(g.kind, "Multi")
+		_ = binary.Write(b, binary.LittleEndian, uint32(len(children)))
+		for _, child := range children {
+			(&shape{kind: kind, dim: g.dimension(), coords: child}).writeWKB(b)
+		}
+	default:
+		writeWKBCoords(b, g.coords)
+	}
+}
+
+func writeWKBCoords(b *bytes.Buffer, coords interface{}) {
+	switch c := coords.(type) {
+	case []float64:
+		_ = binary.Write(b, binary.LittleEndian, c)
+	case []interface{}:
+		_ = binary.Write(b, binary.LittleEndian, uint32(len(c)))
+		for _, child := range c {
+			writeWKBCoords(b, child)
+		}
+	default:
+		_ = binary.Write(b, binary.LittleEndian, uint32(0))
+	}
+}
+
+// parseWKT parse WKT or EWKT, the SRID is ignored
+func parseWKT(text string) (*shape, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(strings.ToUpper(text), "SRID=") {
+		if idx := strings.IndexByte(text, ';'); idx >= 0 {
+			text = text[idx+1:]
+		}
+	}
+
+	p := &wktParser{text: text}
+	g, err := p.geometry()
+	if err == nil && p.skip() < len(p.text) {
+		err = fmt.Errorf("snowflake: unexpected %q in WKT", p.text[p.pos:])
+	}
+	return g, err
+}
+
+type wktParser struct {
+	text string
+	pos  int
+}
+
+func (p *wktParser) skip() int {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t' || p.text[p.pos] == '\n' || p.text[p.pos] == '\r') {
+		p.pos++
+	}
+	return p.pos
+}
+
+func (p *wktParser) peek() byte {
+	if p.skip() < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+func (p *wktParser) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("snowflake: expected %q at %d in WKT", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *wktParser) word() string {
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.text) && (p.text[p.pos] >= 'A' && p.text[p.pos] <= 'Z' || p.text[p.pos] >= 'a' && p.text[p.pos] <= 'z') {
+		p.pos++
+	}
+	return strings.ToUpper(p.text[start:p.pos])
+}
+
+func (p *wktParser) geometry() (*shape, error) {
+	name := p.word()
+	kind, ok := kindOf(name)
+	if !ok {
+		return nil, fmt.Errorf("snowflake: unknown WKT geometry %q", name)
+	}
+
+	g := &shape{kind: kind}
+	start := p.pos
+	switch word := p.word(); word {
+	case "EMPTY":
+		return g, nil
+	case "Z", "M", "ZM":
+		g.dim = word
+		if p.skip(); strings.HasPrefix(strings.ToUpper(p.text[p.pos:]), "EMPTY") {
+			p.word()
+			return g, nil
+		}
+	default:
+		p.pos = start
+	}
+
+	if err := p.body(g); err != nil {
+		return nil, err
+	}
+	if g.dim != "" && kind != "GeometryCollection" && g.dims() != 2+len(g.dim) {
+		return nil, fmt.Errorf("snowflake: %s %s WKT with %d coordinates", wktKinds[kind].name, g.dim, g.dims())
+	}
+	return g, nil
+}
+
+// body parse the coordinates or geometries of g
+func (p *wktParser) body(g *shape) error {
+	if g.kind == "GeometryCollection" {
+		if err := p.expect('('); err != nil {
+			return err
+		}
+		for {
+			child, err := p.geometry()
+			if err != nil {
+				return err
+			}
+			g.geometries = append(g.geometries, child)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		return p.expect(')')
+	}
+
+	depth := wktKinds[g.kind].depth
+	if depth == 0 {
+		if err := p.expect('('); err != nil {
+			return err
+		}
+		position, err := p.position()
+		if err != nil {
+			return err
+		}
+		g.coords = position
+		return p.expect(')')
+	}
+
+	coords, err := p.coords(depth)
+	g.coords = coords
+	return err
+}
+
+// coords a parenthesized list nested depth times, positions may be parenthesized (MULTIPOINT((1 2),(3 4)))
+func (p *wktParser) coords(depth int) (interface{}, error) {
+	if depth == 0 {
+		if p.peek() == '(' {
+			p.pos++
+			position, err := p.position()
+			if err != nil {
+				return nil, err
+			}
+			return position, p.expect(')')
+		}
+		return p.position()
+	}
+
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var list []interface{}
+	for {
+		child, err := p.coords(depth - 1)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, child)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	return list, p.expect(')')
+}
+
+func (p *wktParser) position() ([]float64, error) {
+	var position []float64
+	for {
+		p.skip()
+		start := p.pos
+		for p.pos < len(p.text) && strings.IndexByte("0123456789+-.eE", p.text[p.pos]) >= 0 {
+			p.pos++
+		}
+		if start == p.pos {
+			break
+		}
+
+		v, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+		if err != nil {
+			return nil, err
+		}
+		position = append(position, v)
+	}
+
+	if len(position) < 2 {
+		return nil, fmt.Errorf("snowflake: expected coordinates at %d in WKT", p.pos)
+	}
+	return position, nil
+}
+
+func parseGeoJSON(data []byte) (*shape, error) {
+	var object struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometries  []json.RawMessage `json:"geometries"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	kind, ok := kindOf(object.Type)
+	if !ok {
+		return nil, fmt.Errorf("snowflake: unknown GeoJSON type %q", object.Type)
+	}
+
+	g := &shape{kind: kind}
+	if kind == "GeometryCollection" {
+		for _, raw := range object.Geometries {
+			child, err := parseGeoJSON(raw)
+			if err != nil {
+				return nil, err
+			}
+			g.geometries = append(g.geometries, child)
+		}
+		return g, nil
+	}
+
+	var coords interface{}
+	if len(object.Coordinates) > 0 {
+		if err := json.Unmarshal(object.Coordinates, &coords); err != nil {
+			return nil, err
+		}
+	}
+	converted, err := geoJSONCoords(coords, wktKinds[kind].depth)
+	g.coords = converted
+	return g, err
+}
+
+// geoJSONCoords convert decoded JSON coordinates, positions to []float64
+func geoJSONCoords(coords interface{}, depth int) (interface{}, error) {
+	list, ok := coords.([]interface{})
+	if !ok {
+		if coords == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("snowflake: invalid GeoJSON coordinates %v", coords)
+	}
+
+	if depth == 0 {
+		position := make([]float64, len(list))
+		for idx, v := range list {
+			f, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("snowflake: invalid GeoJSON position %v", list)
+			}
+			position[idx] = f
+		}
+		return position, nil
+	}
+
+	converted := make([]interface{}, len(list))
+	for idx, child := range list {
+		c, err := geoJSONCoords(child, depth-1)
+		if err != nil {
+			return nil, err
+		}
+		converted[idx] = c
+	}
+	return converted, nil
+}
+
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, errors.New("snowflake: truncated WKB")
+	}
+	v := r.order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+func (r *wkbReader) position(dims int) ([]float64, error) {
+	if r.pos+8*dims > len(r.data) {
+		return nil, errors.New("snowflake: truncated WKB")
+	}
+	position := make([]float64, dims)
+	for idx := range position {
+		position[idx] = math.Float64frombits(r.order.Uint64(r.data[r.pos:]))
+		r.pos += 8
+	}
+	return position, nil
+}
+
+func (r *wkbReader) list(depth, dims int) (interface{}, error) {
+	if depth == 0 {
+		return r.position(dims)
+	}
+
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	// the count is read from the input, check it fits before allocating: a count or a position per element
+	elemSize := 4
+	if depth == 1 {
+		elemSize = 8 * dims
+	}
+	if uint64(n)*uint64(elemSize) > uint64(len(r.data)-r.pos) {
+		return nil, errors.New("snowflake: truncated WKB")
+	}
+	list := make([]interface{}, 0, n)
+	for i := uint32(0); i < n; i++ {
+		child, err := r.list(depth-1, dims)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, child)
+	}
+	return list, nil
+}
+
+// read a WKB (ISO or EWKB with SRID) geometry
+func (r *wkbReader) read() (*shape, error) {
+	if r.pos >= len(r.data) {
+		return nil, errors.New("snowflake: truncated WKB")
+	}
+	r.order = binary.ByteOrder(binary.BigEndian)
+	if r.data[r.pos] == 1 {
+		r.order = binary.LittleEndian
+	}
+	r.pos++
+
+	code, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	var z, m bool
+	if code&0x80000000 != 0 {
+		z = true
+	}
+	if code&0x40000000 != 0 {
+		m = true
+	}
+	if code&0x20000000 != 0 {
+		// EWKB SRID
+		if _, err := r.uint32(); err != nil {
+			return nil, err
+		}
+	}
+	code &= 0x0fffffff
+	switch code / 1000 {
+	case 1:
+		z = true
+	case 2:
+		m = true
+	case 3:
+		z, m = true, true
+	}
+	code %= 1000
+
+	dims, dim := 2, ""
+	if z {
+		dims, dim = dims+1, "Z"
+	}
+	if m {
+		dims, dim = dims+1, dim+"M"
+	}
+
+	var kind string
+	for name, k := range wktKinds {
+		if k.code == code {
+			kind = name
+		}
+	}
+	if kind == "" {
+		return nil, fmt.Errorf("snowflake: unknown WKB geometry type %d", code)
+	}
+
+	g := &shape{kind: kind, dim: dim}
+	switch kind {
+	case "Point":
+		position, err := r.position(dims)
+		if err != nil {
+			return nil, err
+		}
+		if !math.IsNaN(position[0]) {
+			g.coords = position
+		}
+	case "MultiPoint", "MultiLineString", "MultiPolygon", "GeometryCollection":
+		n, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		var children []interface{}
+		for i := uint32(0); i < n; i++ {
+			child, err := r.read()
+			if err != nil {
+				return nil, err
+			}
+			if kind == "GeometryCollection" {
+				g.geometries = append(g.geometries, child)
+			} else {
+				children = append(children, child.coords)
+			}
+		}
+		if kind != "GeometryCollection" {
+			g.coords = children
+		}
+	default:
+		coords, err := r.list(wktKinds[kind].depth, dims)
+		if err != nil {
+			return nil, err
+		}
+		g.coords = coords
+	}
+	return g, nil
+}
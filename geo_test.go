@@ -0,0 +1,108 @@
+package snowflake
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSpatialRoundTrip(t *testing.T) {
+	for _, wkt := range []string{
+		"POINT(1 2)",
+		"POINT Z(1 2 3)",
+		"POINT M(1 2 3)",
+		"POINT ZM(1 2 3 4)",
+		"POINT EMPTY",
+		"LINESTRING(1 2,3 4.5)",
+		"POLYGON((0 0,1 0,1 1,0 0),(0.2 0.2,0.4 0.2,0.4 0.4,0.2 0.2))",
+		"MULTIPOINT(1 2,3 4)",
+		"MULTIPOINT ZM(1 2 3 4,5 6 7 8)",
+		"LINESTRING M(1 2 3,4 5 6)",
+		"MULTILINESTRING((1 2,3 4),(5 6,7 8))",
+		"MULTIPOLYGON(((0 0,1 0,1 1,0 0)),((2 2,3 2,3 3,2 2)))",
+		"GEOMETRYCOLLECTION(POINT(1 2),LINESTRING(1 2,3 4))",
+	} {
+		g := GeographyFromWKT(wkt)
+		wkb, err := g.WKB()
+		if err != nil {
+			t.Fatal(wkt, err)
+		}
+		if got, err := GeographyFromWKB(wkb).WKT(); err != nil || got != wkt {
+			t.Errorf("%s through WKB %s = %s, %v", wkt, hex.EncodeToString(wkb), got, err)
+		}
+
+		geojson, err := g.GeoJSON()
+		if strings.Contains(wkt, "M(") {
+			if err == nil {
+				t.Errorf("%s as GeoJSON %s drops its M coordinates", wkt, geojson)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(wkt, err)
+		}
+		if got, err := GeographyFromGeoJSON(geojson).WKT(); err != nil || got != wkt {
+			t.Errorf("%s through GeoJSON %s = %s, %v", wkt, geojson, got, err)
+		}
+	}
+}
+
+func TestSpatialMeasuredWKB(t *testing.T) {
+	for _, test := range []struct {
+		wkt, wkb string
+	}{
+		{"POINT M(1 2 3)", "01d1070000000000000000f03f00000000000000400000000000000840"},
+		{"POINT ZM(1 2 3 4)", "01b90b0000000000000000f03f000000000000004000000000000008400000000000001040"},
+		{"MULTIPOINT ZM(1 2 3 4)", "01bc0b00000100000001b90b0000000000000000f03f000000000000004000000000000008400000000000001040"},
+	} {
+		if wkb, err := GeometryFromWKT(test.wkt).WKB(); err != nil || hex.EncodeToString(wkb) != test.wkb {
+			t.Errorf("%s WKB = %x, %v, want %s", test.wkt, wkb, err, test.wkb)
+		}
+	}
+
+	// EWKB flags
+	ewkb, _ := hex.DecodeString("01010000c0000000000000f03f000000000000004000000000000008400000000000001040")
+	if got, err := GeometryFromWKB(ewkb).WKT(); err != nil || got != "POINT ZM(1 2 3 4)" {
+		t.Errorf("EWKB ZM point = %s, %v", got, err)
+	}
+	if _, err := GeometryFromWKT("POINT M(1 2)").WKB(); err == nil {
+		t.Error("M point with 2 coordinates parsed")
+	}
+}
+
+func TestSpatialMalformed(t *testing.T) {
+	for _, wkb := range []string{
+		"",
+		"01",
+		"0101000000000000000000f03f",
+		// a LINESTRING claiming 2^31 points in 9 bytes
+		"0102000000ffffff7f",
+		"0103000000ffffffff",
+		"0104000000ffffffff",
+		"01ff000000",
+	} {
+		data, _ := hex.DecodeString(wkb)
+		if _, err := GeometryFromWKB(data).WKT(); err == nil {
+			t.Errorf("WKB %s parsed", wkb)
+		}
+	}
+
+	for _, wkt := range []string{"", "POINT", "POINT(1)", "POINT(1 2", "LINESTRING(1 2,)", "CIRCLE(1 2)", "POINT(1 2) x"} {
+		if _, err := GeometryFromWKT(wkt).WKB(); err == nil {
+			t.Errorf("WKT %q parsed", wkt)
+		}
+	}
+
+	for _, geojson := range []string{
+		"",
+		"{",
+		`{"type":"Circle","coordinates":[1,2]}`,
+		`{"type":"Point","coordinates":["a",2]}`,
+		`{"type":"LineString","coordinates":[1,2]}`,
+		`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":{}}]}`,
+	} {
+		if _, err := GeometryFromGeoJSON(geojson).WKT(); err == nil {
+			t.Errorf("GeoJSON %q parsed", geojson)
+		}
+	}
+}
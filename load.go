@@ -0,0 +1,665 @@
+package snowflake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// LoadFormat file format models are serialized to before being staged
+type LoadFormat string
+
+const (
+	// LoadCSV gzipped CSV, the default
+	LoadCSV LoadFormat = "CSV"
+	// LoadJSON gzipped newline delimited JSON, one object per model keyed by column name
+	LoadJSON LoadFormat = "JSON"
+	// LoadParquet Parquet files with GZIP compressed pages, columns named after the model's
+	LoadParquet LoadFormat = "PARQUET"
+)
+
+// LoadOptions options of Load
+type LoadOptions struct {
+	// Format of the staged files, LoadCSV by default
+	Format LoadFormat
+	// RowsPerFile split the models into several staged files, 0 puts all of them in one file
+	RowsPerFile int
+	// OnError COPY ON_ERROR option (CONTINUE, SKIP_FILE, SKIP_FILE_<n>, SKIP_FILE_<n>%, ABORT_STATEMENT),
+	// ABORT_STATEMENT by default
+	OnError string
+}
+
+// LoadResult outcome of a COPY INTO, with the rows that were rejected
+type LoadResult struct {
+	Files      []LoadFileResult
+	RowsLoaded int64
+	Rejected   []RejectedRow
+}
+
+// LoadFileResult COPY INTO output for one staged file
+type LoadFileResult struct {
+	File       string
+	Status     string
+	RowsParsed int64
+	RowsLoaded int64
+	ErrorsSeen int64
+	FirstError string
+}
+
+// RejectedRow a row COPY INTO could not load, as returned by VALIDATE
+type RejectedRow struct {
+	File     string
+	Line     int64
+	Column   string
+	Error    string
+	Category string
+	Record   string
+}
+
+// Load bulk load a slice of models into their table: the models are serialized with their schema's column
+// mapping, PUT to a temporary internal stage and copied with COPY INTO, e.g.
+//
+//	result, err := snowflake.Load(db, &events, snowflake.LoadOptions{OnError: "CONTINUE"})
+//
+// Unlike Create, hooks don't run and nothing is read back into the models (IDs, database defaults),
+// auto increment columns and database defaults left zero in every model are filled by Snowflake.
+// The statements run on one pinned session through db's ConnPool.
+func Load(db *gorm.DB, value interface{}, opts LoadOptions) (*LoadResult, error) {
+	onError, err := onErrorOption(opts.OnError)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return nil, err
+	}
+
+	table := stmt.Table
+	if db.Statement.Table != "" {
+		table = db.Statement.Table
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	var models []reflect.Value
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if model := reflect.Indirect(rv.Index(i)); model.Kind() == reflect.Struct {
+				models = append(models, model)
+			}
+		}
+	case reflect.Struct:
+		models = append(models, rv)
+	}
+	if len(models) == 0 {
+		return &LoadResult{}, nil
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = LoadCSV
+	}
+
+	var fileFormat string
+	switch format {
+	case LoadCSV:
+		fileFormat = `TYPE = CSV COMPRESSION = GZIP FIELD_OPTIONALLY_ENCLOSED_BY = '"' EMPTY_FIELD_AS_NULL = TRUE NULL_IF = ()`
+	case LoadJSON:
+		fileFormat = "TYPE = JSON COMPRESSION = GZIP"
+	case LoadParquet:
+		fileFormat = "TYPE = PARQUET"
+	default:
+		return nil, fmt.Errorf("snowflake: unsupported load format %q", format)
+	}
+
+	fields := loadFields(db, stmt.Schema, models)
+	files, err := encodeModels(db, fields, models, format, opts.RowsPerFile)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(fields))
+	for idx, field := range fields {
+		columns[idx] = field.DBName
+	}
+
+	var result *LoadResult
+	err = WithSession(db, func(tx *gorm.DB) error {
+		stage, err := createTemporaryStage(tx)
+		if err != nil {
+			return err
+		}
+		defer tx.Exec("DROP STAGE IF EXISTS " + stage)
+
+		for idx, file := range files {
+			put := tx.WithContext(gosnowflake.WithFileStream(tx.Statement.Context, bytes.NewReader(file)))
+			command := fmt.Sprintf("PUT 'file:///data_%d.%s.gz' @%s AUTO_COMPRESS = FALSE SOURCE_COMPRESSION = GZIP", idx, strings.ToLower(string(format)), stage)
+			if format == LoadParquet {
+				// compressed by page
+				command = fmt.Sprintf("PUT 'file:///data_%d.parquet' @%s AUTO_COMPRESS = FALSE", idx, stage)
+			}
+			if err := put.Exec(command).Error; err != nil {
+				return err
+			}
+		}
+
+		result, err = copyInto(tx, table, "@"+stage, fileFormat, copyOptions{
+			columns:     columns,
+			matchByName: format != LoadCSV,
+			onError:     onError,
+		})
+		return err
+	})
+	return result, err
+}
+
+// LoadFilesOptions options of LoadFiles
+type LoadFilesOptions struct {
+	// Format of the files, LoadCSV (by default), LoadJSON or LoadParquet. JSON and Parquet files are matched to the
+	// columns by name.
+	Format LoadFormat
+	// FormatOptions extra FILE_FORMAT options, e.g. "SKIP_HEADER = 1 FIELD_DELIMITER = ';'"
+	FormatOptions string
+	// Columns of the table in the order of the CSV fields, the model's columns (without auto increment) by default
+	Columns []string
+	// OnError COPY ON_ERROR option (CONTINUE, SKIP_FILE, SKIP_FILE_<n>, SKIP_FILE_<n>%, ABORT_STATEMENT),
+	// ABORT_STATEMENT by default
+	OnError string
+	// ValidationMode COPY VALIDATION_MODE (RETURN_ERRORS, RETURN_ALL_ERRORS, RETURN_<n>_ROWS) to check
+	// the files without loading them, the errors are returned in LoadResult.Rejected
+	ValidationMode string
+}
+
+// LoadFiles copy local files (paths may contain PUT wildcards) into the table of model: they are PUT to a
+// temporary internal stage, compressed unless already compressed or Parquet, and loaded with COPY INTO, e.g.
+//
+//	result, err := snowflake.LoadFiles(db, &Event{}, []string{"/data/events_*.csv"}, snowflake.LoadFilesOptions{
+//		FormatOptions: "SKIP_HEADER = 1",
+//		OnError:       "CONTINUE",
+//	})
+func LoadFiles(db *gorm.DB, model interface{}, paths []string, opts LoadFilesOptions) (*LoadResult, error) {
+	onError, err := onErrorOption(opts.OnError)
+	if err != nil {
+		return nil, err
+	}
+	validationMode, err := validationModeOption(opts.ValidationMode)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+
+	table := stmt.Table
+	if db.Statement.Table != "" {
+		table = db.Statement.Table
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = LoadCSV
+	}
+
+	columns := opts.Columns
+	if len(columns) == 0 {
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && field.Creatable && !field.AutoIncrement {
+				columns = append(columns, field.DBName)
+			}
+		}
+	}
+
+	var fileFormat string
+	switch format {
+	case LoadCSV:
+		fileFormat = "TYPE = CSV"
+	case LoadJSON:
+		fileFormat = "TYPE = JSON"
+	case LoadParquet:
+		fileFormat = "TYPE = PARQUET"
+	default:
+		return nil, fmt.Errorf("snowflake: unsupported load format %q", format)
+	}
+	if opts.FormatOptions != "" {
+		fileFormat += " " + opts.FormatOptions
+	}
+
+	autoCompress := "TRUE"
+	if format == LoadParquet {
+		autoCompress = "FALSE"
+	}
+
+	var result *LoadResult
+	err = WithSession(db, func(tx *gorm.DB) error {
+		stage, err := createTemporaryStage(tx)
+		if err != nil {
+			return err
+		}
+		defer tx.Exec("DROP STAGE IF EXISTS " + stage)
+
+		for _, path := range paths {
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+
+			if err := tx.Exec(fmt.Sprintf("PUT '%s' @%s AUTO_COMPRESS = %s", fileURL(path), stage, autoCompress)).Error; err != nil {
+				return err
+			}
+		}
+
+		result, err = copyInto(tx, table, "@"+stage, fileFormat, copyOptions{
+			columns:        columns,
+			matchByName:    format != LoadCSV,
+			onError:        onError,
+			validationMode: validationMode,
+		})
+		return err
+	})
+	return result, err
+}
+
+// loadFields the columns to load, leaving out auto increment columns and database defaults zero in every model
+func loadFields(db *gorm.DB, sch *schema.Schema, models []reflect.Value) []*schema.Field {
+	var fields []*schema.Field
+	for _, field := range sch.Fields {
+		if field.DBName == "" || !field.Creatable || field.AutoIncrement {
+			continue
+		}
+
+		if field.HasDefaultValue && field.DefaultValueInterface == nil {
+			set := false
+			for _, model := range models {
+				if _, isZero := field.ValueOf(db.Statement.Context, model); !isZero {
+					set = true
+					break
+				}
+			}
+			if !set {
+				continue
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// encodeModels serialize the models into gzipped files (or Parquet files with compressed pages) of at most
+// rowsPerFile rows
+func encodeModels(db *gorm.DB, fields []*schema.Field, models []reflect.Value, format LoadFormat, rowsPerFile int) ([][]byte, error) {
+	if rowsPerFile <= 0 {
+		rowsPerFile = len(models)
+	}
+
+	var (
+		ctx   = db.Statement.Context
+		now   = db.NowFunc()
+		files [][]byte
+	)
+	for start := 0; start < len(models); start += rowsPerFile {
+		end := start + rowsPerFile
+		if end > len(models) {
+			end = len(models)
+		}
+
+		var (
+			buf     bytes.Buffer
+			w       = gzip.NewWriter(&buf)
+			columns = make([]parquetColumn, len(fields))
+		)
+		for idx, field := range fields {
+			columns[idx].name = field.DBName
+		}
+		for _, model := range models[start:end] {
+			row := make([]interface{}, len(fields))
+			for idx, field := range fields {
+				value, isZero := field.ValueOf(ctx, model)
+				if isZero && (field.AutoCreateTime > 0 || field.AutoUpdateTime > 0) {
+					value = autoTime(field, now)
+				}
+
+				v, ok := arrayBindValue(db, field, value)
+				if !ok {
+					return nil, fmt.Errorf("snowflake: can't load %v into column %s", value, field.DBName)
+				}
+				row[idx] = v
+			}
+
+			var err error
+			switch format {
+			case LoadJSON:
+				err = writeJSONRow(w, fields, row)
+			case LoadParquet:
+				for idx, value := range row {
+					columns[idx].values = append(columns[idx].values, value)
+				}
+			default:
+				err = writeCSVRow(w, row)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if format == LoadParquet {
+			file, err := encodeParquet(columns)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+			continue
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		files = append(files, buf.Bytes())
+	}
+	return files, nil
+}
+
+// autoTime the value gorm would set for an autoCreateTime/autoUpdateTime field
+func autoTime(field *schema.Field, now time.Time) interface{} {
+	unit := field.AutoCreateTime
+	if unit == 0 {
+		unit = field.AutoUpdateTime
+	}
+
+	switch {
+	case field.DataType == schema.Time:
+		return now
+	case unit == schema.UnixNanosecond:
+		return now.UnixNano()
+	case unit == schema.UnixMillisecond:
+		return now.UnixNano() / 1e6
+	}
+	return now.Unix()
+}
+
+// writeCSVRow every non NULL value is enclosed in quotes, so empty strings aren't loaded as NULL
+func writeCSVRow(w *gzip.Writer, row []interface{}) error {
+	var b strings.Builder
+	for idx, value := range row {
+		if idx > 0 {
+			b.WriteByte(',')
+		}
+		if value == nil {
+			continue
+		}
+		b.WriteByte('"')
+		b.WriteString(strings.ReplaceAll(loadText(value), `"`, `""`))
+		b.WriteByte('"')
+	}
+	b.WriteByte('\n')
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// writeJSONRow one object keyed by column name, bytes are hex encoded
+func writeJSONRow(w *gzip.Writer, fields []*schema.Field, row []interface{}) error {
+	object := make(map[string]interface{}, len(fields))
+	for idx, field := range fields {
+		if bytes, ok := row[idx].([]byte); ok {
+			object[field.DBName] = hex.EncodeToString(bytes)
+		} else {
+			object[field.DBName] = row[idx]
+		}
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// loadText text of a value converted by arrayBindValue
+func loadText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return hex.EncodeToString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// fileURL file:// URL of an absolute local path for PUT and GET, quotes escaped
+func fileURL(path string) string {
+	path = strings.ReplaceAll(filepath.ToSlash(path), "'", `\'`)
+	if !strings.HasPrefix(path, "/") {
+		// windows drive
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+// createTemporaryStage create an uniquely named temporary internal stage, dropped with the session
+func createTemporaryStage(tx *gorm.DB) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	stage := "gorm_stage_" + hex.EncodeToString(suffix)
+	return stage, tx.Exec("CREATE TEMPORARY STAGE " + stage).Error
+}
+
+var (
+	onErrorRegexp        = regexp.MustCompile(`^(?i)(CONTINUE|SKIP_FILE|SKIP_FILE_\d+|SKIP_FILE_\d+%|ABORT_STATEMENT)$`)
+	validationModeRegexp = regexp.MustCompile(`^(?i)(RETURN_\d+_ROWS|RETURN_ERRORS|RETURN_ALL_ERRORS)$`)
+)
+
+// onErrorOption the ON_ERROR value of a COPY, a percentage is quoted. Quotes around the value are optional.
+func onErrorOption(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	option := strings.ToUpper(strings.Trim(value, "'"))
+	if !onErrorRegexp.MatchString(option) {
+		return "", fmt.Errorf("snowflake: invalid ON_ERROR %q", value)
+	}
+	if strings.HasSuffix(option, "%") {
+		option = "'" + option + "'"
+	}
+	return option, nil
+}
+
+// validationModeOption the VALIDATION_MODE value of a COPY
+func validationModeOption(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	option := strings.ToUpper(value)
+	if !validationModeRegexp.MatchString(option) {
+		return "", fmt.Errorf("snowflake: invalid VALIDATION_MODE %q", value)
+	}
+	return option, nil
+}
+
+// copyOptions how COPY INTO maps and validates the staged files, onError and validationMode are checked
+// by onErrorOption and validationModeOption
+type copyOptions struct {
+	columns        []string
+	matchByName    bool
+	onError        string
+	validationMode string
+}
+
+// copyInto run COPY INTO table from the staged files and collect its per file results, with the rejected rows
+// from VALIDATE when errors were skipped, or from the COPY output itself when only validating
+func copyInto(tx *gorm.DB, table, location, fileFormat string, opts copyOptions) (*LoadResult, error) {
+	var sql strings.Builder
+	sql.WriteString("COPY INTO ")
+	sql.WriteString(tx.Statement.Quote(table))
+	if !opts.matchByName && len(opts.columns) > 0 {
+		sql.WriteString(" (")
+		for idx, column := range opts.columns {
+			if idx > 0 {
+				sql.WriteByte(',')
+			}
+			sql.WriteString(tx.Statement.Quote(column))
+		}
+		sql.WriteByte(')')
+	}
+	sql.WriteString(" FROM ")
+	sql.WriteString(location)
+	sql.WriteString(" FILE_FORMAT = (")
+	sql.WriteString(fileFormat)
+	sql.WriteByte(')')
+	if opts.matchByName {
+		sql.WriteString(" MATCH_BY_COLUMN_NAME = CASE_INSENSITIVE")
+	}
+	if opts.onError != "" {
+		sql.WriteString(" ON_ERROR = ")
+		sql.WriteString(opts.onError)
+	}
+	if opts.validationMode != "" {
+		sql.WriteString(" VALIDATION_MODE = ")
+		sql.WriteString(opts.validationMode)
+	}
+
+	records, err := queryRecords(tx, sql.String())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LoadResult{}
+	if opts.validationMode != "" {
+		// RETURN_ERRORS and RETURN_ALL_ERRORS return the rejected rows, RETURN_<n>_ROWS fails on the first one
+		if strings.Contains(strings.ToUpper(opts.validationMode), "ERRORS") {
+			result.Rejected = rejectedRows(records)
+		}
+		return result, nil
+	}
+
+	errorsSeen := false
+	for _, record := range records {
+		file := LoadFileResult{
+			File:       recordString(record["file"]),
+			Status:     recordString(record["status"]),
+			RowsParsed: recordInt(record["rows_parsed"]),
+			RowsLoaded: recordInt(record["rows_loaded"]),
+			ErrorsSeen: recordInt(record["errors_seen"]),
+			FirstError: recordString(record["first_error"]),
+		}
+		if file.File == "" {
+			// no files processed
+			continue
+		}
+		result.Files = append(result.Files, file)
+		result.RowsLoaded += file.RowsLoaded
+		errorsSeen = errorsSeen || file.ErrorsSeen > 0
+	}
+
+	if errorsSeen {
+		records, err := queryRecords(tx, "SELECT * FROM TABLE(VALIDATE("+tx.Statement.Quote(table)+", JOB_ID => '_last'))")
+		if err != nil {
+			return result, err
+		}
+		result.Rejected = rejectedRows(records)
+	}
+	return result, nil
+}
+
+// rejectedRows from the output of VALIDATE or of COPY with VALIDATION_MODE = RETURN_ERRORS
+func rejectedRows(records []map[string]interface{}) []RejectedRow {
+	rejected := make([]RejectedRow, 0, len(records))
+	for _, record := range records {
+		rejected = append(rejected, RejectedRow{
+			File:     recordString(record["file"]),
+			Line:     recordInt(record["line"]),
+			Column:   recordString(record["column_name"]),
+			Error:    recordString(record["error"]),
+			Category: recordString(record["category"]),
+			Record:   recordString(record["rejected_record"]),
+		})
+	}
+	return rejected
+}
+
+// queryRecords rows of a query whose columns aren't known upfront, keyed by lower case column name
+func queryRecords(tx *gorm.DB, sql string) ([]map[string]interface{}, error) {
+	rows, err := tx.Raw(sql).Rows()
+	if err != nil {
+		return nil, err
+	}
+	return scanRecords(rows)
+}
+
+// scanRecords scan and close rows, see queryRecords
+func scanRecords(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for idx := range values {
+			pointers[idx] = &values[idx]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for idx, column := range columns {
+			record[strings.ToLower(column)] = values[idx]
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func recordString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(value)
+}
+
+func recordInt(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	i, _ := strconv.ParseInt(recordString(value), 10, 64)
+	return i
+}
@@ -0,0 +1,108 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+)
+
+type loadEvent struct {
+	ID   int64 `gorm:"primaryKey;autoIncrement"`
+	Name string
+}
+
+func TestLoad(t *testing.T) {
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		switch query := statement.query; {
+		case strings.HasPrefix(query, "COPY INTO"):
+			return &fakeRows{
+				columns: []string{"file", "status", "rows_parsed", "rows_loaded", "errors_seen", "first_error"},
+				values:  [][]driver.Value{{"data_0.csv.gz", "PARTIALLY_LOADED", "2", "1", "1", "bad row"}},
+			}, nil
+		case strings.Contains(query, "VALIDATE("):
+			return &fakeRows{
+				columns: []string{"error", "file", "line", "column_name", "category", "rejected_record"},
+				values:  [][]driver.Value{{"bad row", "data_0.csv.gz", "2", "name", "conversion", `"bob"`}},
+			}, nil
+		}
+		return nil, nil
+	})
+
+	events := []loadEvent{{Name: "alice"}, {Name: "bob"}}
+	result, err := Load(db, &events, LoadOptions{OnError: "skip_file_10%"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := d.queries()
+	assertQueries(t, queries,
+		"CREATE TEMPORARY STAGE gorm_stage_",
+		"PUT 'file:///data_0.csv.gz' @gorm_stage_",
+		"COPY INTO load_events (name) FROM @gorm_stage_",
+		"SELECT * FROM TABLE(VALIDATE(load_events, JOB_ID => '_last'))",
+		"DROP STAGE IF EXISTS gorm_stage_",
+	)
+	if !strings.HasSuffix(queries[2], " ON_ERROR = 'SKIP_FILE_10%'") {
+		t.Errorf("copy = %s", queries[2])
+	}
+	if result.RowsLoaded != 1 || len(result.Files) != 1 || len(result.Rejected) != 1 || result.Rejected[0].Line != 2 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestLoadParquet(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+
+	events := []loadEvent{{Name: "alice"}, {Name: "bob"}}
+	if _, err := Load(db, &events, LoadOptions{Format: LoadParquet}); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := d.queries()
+	assertQueries(t, queries,
+		"CREATE TEMPORARY STAGE gorm_stage_",
+		"PUT 'file:///data_0.parquet' @gorm_stage_",
+		"COPY INTO load_events FROM @gorm_stage_",
+		"DROP STAGE IF EXISTS gorm_stage_",
+	)
+	if !strings.HasSuffix(queries[1], " AUTO_COMPRESS = FALSE") ||
+		!strings.HasSuffix(queries[2], " FILE_FORMAT = (TYPE = PARQUET) MATCH_BY_COLUMN_NAME = CASE_INSENSITIVE") {
+		t.Errorf("queries = %v", queries)
+	}
+}
+
+func TestLoadRefusesInvalidOptions(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+	events := []loadEvent{{Name: "alice"}}
+
+	for _, opts := range []LoadOptions{
+		{OnError: "CONTINUE; DROP TABLE load_events"},
+		{OnError: "SKIP_FILE_%"},
+		{Format: "XML"},
+	} {
+		if _, err := Load(db, &events, opts); err == nil {
+			t.Errorf("%+v accepted", opts)
+		}
+	}
+	if _, err := LoadFiles(db, &loadEvent{}, []string{"events.csv"}, LoadFilesOptions{ValidationMode: "RETURN_ROWS"}); err == nil {
+		t.Error("invalid validation mode accepted")
+	}
+	if _, err := LoadFiles(db, &loadEvent{}, []string{"events.csv"}, LoadFilesOptions{Format: "CSV FIELD_DELIMITER = ';'"}); err == nil {
+		t.Error("invalid format accepted")
+	}
+	if queries := d.queries(); len(queries) > 0 {
+		t.Errorf("queries = %v", queries)
+	}
+}
+
+func TestLoadFilesValidationMode(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+	if _, err := LoadFiles(db, &loadEvent{}, []string{"/data/events.csv"}, LoadFilesOptions{ValidationMode: "return_10_rows", OnError: "'continue'"}); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := d.queries()
+	if len(queries) != 4 || !strings.HasSuffix(queries[2], " ON_ERROR = CONTINUE VALIDATION_MODE = RETURN_10_ROWS") {
+		t.Errorf("queries = %v", queries)
+	}
+}
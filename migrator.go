@@ -1,7 +1,10 @@
 package snowflake
 
 import (
+	"database/sql"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"gorm.io/gorm"
@@ -81,6 +84,7 @@ func (m Migrator) AutoMigrate(values ...interface{}) error {
 
 // CreateTable modified
 // - include CHANGE_TRACKING=true, for getting output back, may be removed once it can globally supported with table options
+// - create the sequences of auto increment columns with IDSequence (CHANGE_TRACKING only when other defaults need it)
 // - remove index (unsupported)
 func (m Migrator) CreateTable(values ...interface{}) error {
 	for _, value := range m.ReorderModels(values, false) {
@@ -92,11 +96,26 @@ func (m Migrator) CreateTable(values ...interface{}) error {
 				hasPrimaryKeyInDataType bool
 			)
 
+			config := configOf(m.DB)
 			for _, dbName := range stmt.Schema.DBNames {
 				field := stmt.Schema.FieldsByDBName[dbName]
+				if errr = checkDataType(field); errr != nil {
+					return errr
+				}
+
 				createTableSQL += "? ?"
 				hasPrimaryKeyInDataType = hasPrimaryKeyInDataType || strings.Contains(strings.ToUpper(string(field.DataType)), "PRIMARY KEY")
-				values = append(values, clause.Column{Name: dbName}, m.DB.Migrator().FullDataTypeOf(field))
+
+				dataType := m.DB.Migrator().FullDataTypeOf(field)
+				if config.usesSequence(field) {
+					sequence := clause.Table{Name: sequenceName(stmt.Table, dbName)}
+					if errr = tx.Exec("CREATE SEQUENCE IF NOT EXISTS ?", sequence).Error; errr != nil {
+						return errr
+					}
+					dataType.SQL += " DEFAULT " + stmt.Quote(sequence) + ".NEXTVAL"
+				}
+
+				values = append(values, clause.Column{Name: dbName}, dataType)
 				createTableSQL += ","
 			}
 
@@ -134,7 +153,9 @@ func (m Migrator) CreateTable(values ...interface{}) error {
 			if tableOption, ok := m.DB.Get("gorm:table_options"); ok {
 				createTableSQL += fmt.Sprint(tableOption)
 			}
-			createTableSQL += " CHANGE_TRACKING = TRUE"
+			if needsChangeTracking(config, stmt.Schema) {
+				createTableSQL += " CHANGE_TRACKING = TRUE"
+			}
 
 			errr = tx.Exec(createTableSQL, values...).Error
 			return errr
@@ -192,7 +213,16 @@ func (m Migrator) DropTable(values ...interface{}) error {
 	for i := len(values) - 1; i >= 0; i-- {
 		tx := m.DB.Session(&gorm.Session{})
 		if err := m.RunWithValue(values[i], func(stmt *gorm.Statement) error {
-			return tx.Exec("DROP TABLE IF EXISTS ?", m.CurrentTable(stmt)).Error
+			if err := tx.Exec("DROP TABLE IF EXISTS ?", m.CurrentTable(stmt)).Error; err != nil {
+				return err
+			}
+
+			// drop the sequences backing auto increment columns
+			if config := configOf(m.DB); stmt.Schema != nil && config.usesSequence(stmt.Schema.PrioritizedPrimaryField) {
+				sequence := clause.Table{Name: sequenceName(stmt.Table, stmt.Schema.PrioritizedPrimaryField.DBName)}
+				return tx.Exec("DROP SEQUENCE IF EXISTS ?", sequence).Error
+			}
+			return nil
 		}); err != nil {
 			return err
 		}
@@ -219,24 +249,207 @@ func (m Migrator) HasColumn(value interface{}, field string) bool {
 	return count > 0
 }
 
-// AlterColumn no change
+// AddColumn check the type of the field before adding its column
+func (m Migrator) AddColumn(value interface{}, name string) error {
+	if err := m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		if field := stmt.Schema.LookUpField(name); field != nil {
+			return checkDataType(field)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return m.Migrator.AddColumn(value, name)
+}
+
+// AlterColumn modified, SET DATA TYPE (Snowflake only changes the type of a column within its kind, e.g. to
+// increase the precision of a NUMBER, and fails otherwise), then the nullability and comment of the field, and drop
+// the default of fields without one (Snowflake can't set the default of an existing column)
 func (m Migrator) AlterColumn(value interface{}, field string) error {
 	return m.RunWithValue(value, func(stmt *gorm.Statement) error {
 		if field := stmt.Schema.LookUpField(field); field != nil {
-			fileType := clause.Expr{SQL: m.DataTypeOf(field)}
-			if field.NotNull {
-				fileType.SQL += " NOT NULL"
+			if err := checkDataType(field); err != nil {
+				return err
 			}
 
-			return m.DB.Exec(
-				"ALTER TABLE ? ALTER COLUMN ? ?",
-				clause.Table{Name: stmt.Table}, clause.Column{Name: field.DBName}, fileType,
-			).Error
+			// the identity of a column can't be altered
+			var (
+				table    = m.CurrentTable(stmt)
+				column   = clause.Column{Name: field.DBName}
+				dataType = strings.SplitN(m.DataTypeOf(field), " IDENTITY", 2)[0]
+			)
+			if err := m.DB.Exec("ALTER TABLE ? ALTER COLUMN ? SET DATA TYPE ?", table, column, clause.Expr{SQL: dataType}).Error; err != nil {
+				return err
+			}
+
+			// primary keys are NOT NULL and their defaults are identities or sequences
+			if !field.PrimaryKey {
+				nullability := "DROP NOT NULL"
+				if field.NotNull {
+					nullability = "SET NOT NULL"
+				}
+				if err := m.DB.Exec("ALTER TABLE ? ALTER COLUMN ? "+nullability, table, column).Error; err != nil {
+					return err
+				}
+
+				if !field.HasDefaultValue {
+					if err := m.DB.Exec("ALTER TABLE ? ALTER COLUMN ? DROP DEFAULT", table, column).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			if field.Comment != "" {
+				return m.DB.Exec("ALTER TABLE ? ALTER COLUMN ? COMMENT ?", table, column, field.Comment).Error
+			}
+			return m.DB.Exec("ALTER TABLE ? ALTER COLUMN ? UNSET COMMENT", table, column).Error
 		}
 		return fmt.Errorf("failed to look up field with name: %s", field)
 	})
 }
 
+// ColumnTypes modified, read from information_schema, the result set types of the driver lack precision and scale
+func (m Migrator) ColumnTypes(value interface{}) ([]gorm.ColumnType, error) {
+	columnTypes := make([]gorm.ColumnType, 0)
+	execErr := m.RunWithValue(value, func(stmt *gorm.Statement) error {
+		tableSchema, table := currentSchema(stmt)
+		rows, err := m.DB.Raw(
+			"SELECT column_name, data_type, character_maximum_length, numeric_precision, numeric_scale, is_nullable, column_default, comment "+
+				"FROM INFORMATION_SCHEMA.columns WHERE table_catalog = ? AND table_schema = ? AND table_name = ? ORDER BY ordinal_position",
+			m.CurrentDatabase(), tableSchema, strings.ToUpper(table),
+		).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				column   migrator.ColumnType
+				nullable string
+			)
+			if err := rows.Scan(
+				&column.NameValue, &column.DataTypeValue, &column.LengthValue, &column.DecimalSizeValue,
+				&column.ScaleValue, &nullable, &column.DefaultValueValue, &column.CommentValue,
+			); err != nil {
+				return err
+			}
+
+			column.NullableValue = sql.NullBool{Bool: nullable == "YES", Valid: true}
+			column.ColumnTypeValue = sql.NullString{String: columnTypeOf(column), Valid: true}
+			columnTypes = append(columnTypes, informationSchemaColumn{column})
+		}
+		return rows.Err()
+	})
+
+	return columnTypes, execErr
+}
+
+// MigrateColumn modified, compare the NUMBER precision and scale and the VARCHAR length of the column with the field
+// (the base migrator compares type names, which information_schema reports by kind, e.g. TEXT for every VARCHAR and
+// NUMBER for every INT), then let the base migrator compare the rest: the kind, nullability, default and comment.
+// Columns of another kind (e.g. TIMESTAMP_NTZ for a TIMESTAMP_TZ field) are left to the base migrator.
+// Strings are only widened, Snowflake doesn't shorten them nor resize BINARY columns, nor change the scale of a NUMBER.
+func (m Migrator) MigrateColumn(value interface{}, field *schema.Field, columnType gorm.ColumnType) error {
+	if field.IgnoreMigration {
+		return nil
+	}
+
+	dataType := m.DataTypeOf(field)
+	if !m.sameKind(dataType, columnType.DatabaseTypeName()) {
+		return m.Migrator.MigrateColumn(value, field, columnType)
+	}
+
+	if precision, scale, ok := numberSize(dataType); ok {
+		if columnPrecision, columnScale, ok := columnType.DecimalSize(); ok {
+			if columnScale != scale {
+				return fmt.Errorf("snowflake: can't change the scale of column %s from %d to %d, Snowflake only alters the precision of a NUMBER",
+					field.DBName, columnScale, scale)
+			}
+
+			changed := columnPrecision != precision
+			if (field.DataType == schema.Int || field.DataType == schema.Uint) && field.Precision == 0 {
+				// any integer NUMBER holding the Go type is equivalent, e.g. NUMBER(38,0) of an INT column
+				changed = columnPrecision < precision
+			}
+			if changed {
+				return m.DB.Migrator().AlterColumn(value, field.DBName)
+			}
+		}
+	}
+
+	if length, ok := m.lengthOf(dataType); ok {
+		if columnLength, ok := columnType.Length(); ok && length > columnLength {
+			return m.DB.Migrator().AlterColumn(value, field.DBName)
+		}
+	}
+
+	// the sizes were compared above, the base migrator compares them with the tags of the field instead
+	return m.Migrator.MigrateColumn(value, field, sizedColumnType{columnType: columnType, fullDataType: m.DB.Migrator().FullDataTypeOf(field).SQL})
+}
+
+// sameKind if dataType and the data type name information_schema reports are the same kind of type
+func (m Migrator) sameKind(dataType, databaseTypeName string) bool {
+	kind := strings.ToLower(strings.TrimSpace(dataType))
+	if idx := strings.IndexAny(kind, "( "); idx >= 0 {
+		kind = kind[:idx]
+	}
+
+	databaseTypeName = strings.ToLower(databaseTypeName)
+	if kind == databaseTypeName {
+		return true
+	}
+	for _, alias := range m.GetTypeAliases(databaseTypeName) {
+		if kind == alias {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTypeAliases the types information_schema reports by another name
+func (m Migrator) GetTypeAliases(databaseTypeName string) []string {
+	switch strings.ToLower(databaseTypeName) {
+	case "text":
+		return []string{"varchar", "string", "char", "character"}
+	case "binary":
+		return []string{"varbinary"}
+	case "number":
+		return []string{"decimal", "numeric", "int", "integer", "bigint"}
+	}
+	return nil
+}
+
+// columnTypeValues migrator.ColumnType embedded under another name than its ColumnType method
+type columnTypeValues = migrator.ColumnType
+
+// informationSchemaColumn a column read from information_schema, without the driver's column type migrator.ColumnType
+// falls back to for the sizes a column doesn't have
+type informationSchemaColumn struct {
+	columnTypeValues
+}
+
+func (c informationSchemaColumn) Length() (int64, bool) {
+	return c.LengthValue.Int64, c.LengthValue.Valid
+}
+
+func (c informationSchemaColumn) DecimalSize() (int64, int64, bool) {
+	return c.DecimalSizeValue.Int64, c.ScaleValue.Int64, c.DecimalSizeValue.Valid
+}
+
+// columnType gorm.ColumnType embedded under another name than its ColumnType method
+type columnType = gorm.ColumnType
+
+// sizedColumnType a column whose size MigrateColumn already compared, reporting the data type of its field
+type sizedColumnType struct {
+	columnType
+	fullDataType string
+}
+
+func (c sizedColumnType) DatabaseTypeName() string {
+	return c.fullDataType
+}
+
 // RenameColumn not supported
 func (m Migrator) RenameColumn(value interface{}, oldName, newName string) error {
 	return fmt.Errorf("RENAME COLUMN UNSUPPORTED")
@@ -391,6 +604,85 @@ func (m Migrator) FullDataTypeOf(field *schema.Field) (expr clause.Expr) {
 	return
 }
 
+// currentSchema the schema and name of the statement's table, schema-qualified (db.Table("analytics.events") keeps
+// the schema in TableExpr) or in CURRENT_SCHEMA()
+func currentSchema(stmt *gorm.Statement) (interface{}, string) {
+	qualified := stmt.Table
+	if stmt.TableExpr != nil && !strings.Contains(qualified, ".") {
+		qualified = stmt.TableExpr.SQL
+	}
+
+	table := stmt.Table[strings.LastIndex(stmt.Table, ".")+1:]
+	if parts := strings.Split(qualified, "."); len(parts) == 2 {
+		return strings.ToUpper(parts[0]), table
+	}
+	return clause.Expr{SQL: "CURRENT_SCHEMA()"}, table
+}
+
+// needsChangeTracking if rows written to the table have to be read back with CHANGES,
+// with sequence backed IDs only other database defaults need it
+func needsChangeTracking(config *Config, sch *schema.Schema) bool {
+	if config.IDStrategy != IDSequence {
+		return true
+	}
+
+	for _, field := range sch.FieldsWithDefaultDBValue {
+		if !config.usesSequence(field) {
+			return true
+		}
+	}
+	return false
+}
+
+var numberRegexp = regexp.MustCompile(`^(?i)NUMBER(\s*\(\s*(\d+)\s*(,\s*(\d+)\s*)?\))?`)
+
+// numberSize the precision and scale of a NUMBER data type
+func numberSize(dataType string) (precision int64, scale int64, ok bool) {
+	matches := numberRegexp.FindStringSubmatch(strings.TrimSpace(dataType))
+	if matches == nil {
+		return 0, 0, false
+	}
+
+	precision = 38
+	if matches[2] != "" {
+		precision, _ = strconv.ParseInt(matches[2], 10, 64)
+	}
+	if matches[4] != "" {
+		scale, _ = strconv.ParseInt(matches[4], 10, 64)
+	}
+	return precision, scale, true
+}
+
+var lengthRegexp = regexp.MustCompile(`^(?i)VARCHAR(\s*\(\s*(\d+)\s*\))?$`)
+
+// lengthOf the length of a VARCHAR data type, the dialector's maximum when it has none
+func (m Migrator) lengthOf(dataType string) (length int64, ok bool) {
+	matches := lengthRegexp.FindStringSubmatch(strings.TrimSpace(dataType))
+	switch {
+	case matches == nil:
+		return 0, false
+	case matches[2] != "":
+		length, _ = strconv.ParseInt(matches[2], 10, 64)
+		return length, true
+	}
+	if dialector, ok := m.Dialector.(Dialector); ok {
+		return int64(dialector.maxStringSize()), true
+	}
+	return DefaultMaxStringSize, true
+}
+
+// columnTypeOf the data type of the column, with its size
+func columnTypeOf(column migrator.ColumnType) string {
+	switch dataType := column.DataTypeValue.String; {
+	case dataType == "NUMBER" && column.DecimalSizeValue.Valid:
+		return fmt.Sprintf("NUMBER(%d,%d)", column.DecimalSizeValue.Int64, column.ScaleValue.Int64)
+	case (dataType == "TEXT" || dataType == "BINARY") && column.LengthValue.Valid:
+		return fmt.Sprintf("%s(%d)", dataType, column.LengthValue.Int64)
+	default:
+		return dataType
+	}
+}
+
 func buildConstraint(constraint *schema.Constraint) (sql string, results []interface{}) {
 	sql = "CONSTRAINT ? FOREIGN KEY ? REFERENCES ??"
 	if constraint.OnDelete != "" {
@@ -0,0 +1,314 @@
+package snowflake
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+type unsizedDocument struct {
+	ID        int64
+	Embedding Vector
+}
+
+type sizedDocument struct {
+	ID        int64
+	Embedding Vector `gorm:"size:3"`
+}
+
+func TestVectorNeedsSize(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+
+	if err := db.Migrator().CreateTable(&unsizedDocument{}); err == nil || !strings.Contains(err.Error(), "size") {
+		t.Errorf("create table: %v", err)
+	}
+	if err := db.Migrator().AddColumn(&unsizedDocument{}, "Embedding"); err == nil {
+		t.Error("column added")
+	}
+	if err := db.Migrator().AlterColumn(&unsizedDocument{}, "Embedding"); err == nil {
+		t.Error("column altered")
+	}
+	if queries := d.queries(); len(queries) > 0 {
+		t.Fatalf("queries = %v", queries)
+	}
+
+	if err := db.Migrator().CreateTable(&sizedDocument{}); err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(), "CREATE TABLE sized_documents (id NUMBER(19,0) IDENTITY(1,1),embedding VECTOR(FLOAT, 3),PRIMARY KEY (id))")
+}
+
+type migratedEvent struct {
+	ID     int64
+	Name   string  `gorm:"size:256;not null"`
+	Amount float64 `gorm:"type:NUMBER(19,4)"`
+	At     time.Time
+	Note   string `gorm:"comment:free text"`
+}
+
+// eventColumns the columns of migratedEvent as created
+func eventColumns() map[string]informationSchemaColumn {
+	column := func(name, dataType string, length, precision, scale int64, nullable bool, comment string) informationSchemaColumn {
+		c := migrator.ColumnType{
+			NameValue:        sql.NullString{String: name, Valid: true},
+			DataTypeValue:    sql.NullString{String: dataType, Valid: true},
+			LengthValue:      sql.NullInt64{Int64: length, Valid: length > 0},
+			DecimalSizeValue: sql.NullInt64{Int64: precision, Valid: dataType == "NUMBER"},
+			ScaleValue:       sql.NullInt64{Int64: scale, Valid: dataType == "NUMBER"},
+			NullableValue:    sql.NullBool{Bool: nullable, Valid: true},
+			CommentValue:     sql.NullString{String: comment, Valid: comment != ""},
+		}
+		c.ColumnTypeValue = sql.NullString{String: columnTypeOf(c), Valid: true}
+		return informationSchemaColumn{c}
+	}
+	return map[string]informationSchemaColumn{
+		"id":     column("id", "NUMBER", 0, 38, 0, false, ""),
+		"name":   column("name", "TEXT", 256, 0, 0, false, ""),
+		"amount": column("amount", "NUMBER", 0, 19, 4, true, ""),
+		"at":     column("at", "TIMESTAMP_NTZ", 0, 0, 0, true, ""),
+		"note":   column("note", "TEXT", 16777216, 0, 0, true, "free text"),
+	}
+}
+
+func TestMigrateColumn(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&migratedEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	migrate := func(name string, column informationSchemaColumn) error {
+		d.mu.Lock()
+		d.statements = nil
+		d.mu.Unlock()
+		return db.Migrator().MigrateColumn(&migratedEvent{}, stmt.Schema.LookUpField(name), column)
+	}
+
+	for name, column := range eventColumns() {
+		if err := migrate(name, column); err != nil {
+			t.Fatal(name, err)
+		}
+		if queries := d.queries(); len(queries) > 0 {
+			t.Errorf("%s altered: %v", name, queries)
+		}
+	}
+
+	column := eventColumns()["name"]
+	column.LengthValue.Int64 = 100
+	if err := migrate("name", column); err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"ALTER TABLE migrated_events ALTER COLUMN name SET DATA TYPE VARCHAR(256)",
+		"ALTER TABLE migrated_events ALTER COLUMN name SET NOT NULL",
+		"ALTER TABLE migrated_events ALTER COLUMN name DROP DEFAULT",
+		"ALTER TABLE migrated_events ALTER COLUMN name UNSET COMMENT",
+	)
+
+	column = eventColumns()["amount"]
+	column.ScaleValue.Int64 = 2
+	if err := migrate("amount", column); err == nil || !strings.Contains(err.Error(), "scale") {
+		t.Errorf("scale changed: %v", err)
+	}
+	if queries := d.queries(); len(queries) > 0 {
+		t.Errorf("amount altered: %v", queries)
+	}
+
+	column = eventColumns()["at"]
+	column.DataTypeValue.String = "TIMESTAMP_TZ"
+	if err := migrate("at", column); err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"ALTER TABLE migrated_events ALTER COLUMN at SET DATA TYPE TIMESTAMP_NTZ",
+		"ALTER TABLE migrated_events ALTER COLUMN at DROP NOT NULL",
+		"ALTER TABLE migrated_events ALTER COLUMN at DROP DEFAULT",
+		"ALTER TABLE migrated_events ALTER COLUMN at UNSET COMMENT",
+	)
+
+	column = eventColumns()["note"]
+	column.CommentValue.String = "old"
+	if err := migrate("note", column); err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"ALTER TABLE migrated_events ALTER COLUMN note SET DATA TYPE VARCHAR",
+		"ALTER TABLE migrated_events ALTER COLUMN note DROP NOT NULL",
+		"ALTER TABLE migrated_events ALTER COLUMN note DROP DEFAULT",
+		"ALTER TABLE migrated_events ALTER COLUMN note COMMENT ?",
+	)
+}
+
+func TestMigrateUnsizedColumnToMaxStringSize(t *testing.T) {
+	db, d := openFake(t, Config{MaxStringSize: 134217728}, nil)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&migratedEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the note column was created as VARCHAR before the account had 128MB strings
+	if err := db.Migrator().MigrateColumn(&migratedEvent{}, stmt.Schema.LookUpField("note"), eventColumns()["note"]); err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"ALTER TABLE migrated_events ALTER COLUMN note SET DATA TYPE VARCHAR",
+		"ALTER TABLE migrated_events ALTER COLUMN note DROP NOT NULL",
+		"ALTER TABLE migrated_events ALTER COLUMN note DROP DEFAULT",
+		"ALTER TABLE migrated_events ALTER COLUMN note COMMENT ?",
+	)
+}
+
+type sizedStrings struct {
+	Code      string `gorm:"primaryKey"`
+	Plain     string
+	Sized     string `gorm:"size:100"`
+	Indexed   string `gorm:"index"`
+	Large     string `gorm:"size:20000000"`
+	Data      []byte
+	SizedData []byte `gorm:"size:100"`
+	HalfData  []byte `gorm:"size:8388608"`
+	LargeData []byte `gorm:"size:8388609"`
+}
+
+func TestDataTypeOfSizes(t *testing.T) {
+	s, err := schema.Parse(&sizedStrings{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   map[string]string
+	}{
+		{"defaults", Config{}, map[string]string{
+			"Code":      "VARCHAR(256)",
+			"Plain":     "VARCHAR",
+			"Sized":     "VARCHAR(100)",
+			"Indexed":   "VARCHAR(256)",
+			"Large":     "VARCHAR",
+			"Data":      "VARBINARY",
+			"SizedData": "BINARY(100)",
+			"HalfData":  "BINARY(8388608)",
+			"LargeData": "VARBINARY",
+		}},
+		{"default string size", Config{DefaultStringSize: 1024}, map[string]string{
+			"Code":      "VARCHAR(1024)",
+			"Plain":     "VARCHAR(1024)",
+			"Sized":     "VARCHAR(100)",
+			"Indexed":   "VARCHAR(1024)",
+			"Large":     "VARCHAR",
+			"SizedData": "BINARY(100)",
+		}},
+		{"max string size", Config{MaxStringSize: 134217728}, map[string]string{
+			"Plain":     "VARCHAR",
+			"Large":     "VARCHAR(20000000)",
+			"HalfData":  "BINARY(8388608)",
+			"LargeData": "BINARY(8388609)",
+		}},
+		{"small max string size", Config{MaxStringSize: 200, DefaultStringSize: 300}, map[string]string{
+			"Code":      "VARCHAR",
+			"Plain":     "VARCHAR",
+			"Sized":     "VARCHAR(100)",
+			"SizedData": "BINARY(100)",
+			"HalfData":  "VARBINARY",
+		}},
+	}
+	for _, test := range tests {
+		dialector := New(test.config).(*Dialector)
+		for name, want := range test.want {
+			if got := dialector.DataTypeOf(s.LookUpField(name)); got != want {
+				t.Errorf("%s: %s = %s, want %s", test.name, name, got, want)
+			}
+		}
+	}
+}
+
+type sizedIntegers struct {
+	ID     uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Tiny   int8
+	Small  uint8
+	Medium uint16
+	Count  uint32
+	Signed int
+	Big    int64
+	Exact  uint64 `gorm:"precision:38"`
+}
+
+func TestDataTypeOfIntegers(t *testing.T) {
+	s, err := schema.Parse(&sizedIntegers{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialector := New(Config{}).(*Dialector)
+	for name, want := range map[string]string{
+		"ID":     "NUMBER(20,0)",
+		"Tiny":   "NUMBER(3,0)",
+		"Small":  "NUMBER(3,0)",
+		"Medium": "NUMBER(5,0)",
+		"Count":  "NUMBER(10,0)",
+		"Signed": "NUMBER(19,0)",
+		"Big":    "NUMBER(19,0)",
+		"Exact":  "NUMBER(38,0)",
+	} {
+		if got := dialector.DataTypeOf(s.LookUpField(name)); got != want {
+			t.Errorf("%s = %s, want %s", name, got, want)
+		}
+	}
+}
+
+type sequencedEvent struct {
+	ID        int64 `gorm:"primaryKey;autoIncrement"`
+	Name      string
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP()"`
+}
+
+func TestSequenceIDsMigration(t *testing.T) {
+	db, d := openFake(t, Config{IDStrategy: IDSequence}, nil)
+
+	if err := db.Migrator().CreateTable(&changeUser{}, &sequencedEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().DropTable(&changeUser{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// CHANGE_TRACKING only for the defaults read back with CHANGES
+	queries := d.queries()
+	want := []string{
+		"CREATE SEQUENCE IF NOT EXISTS change_users_id_seq",
+		"CREATE TABLE change_users (id NUMBER(19,0) DEFAULT change_users_id_seq.NEXTVAL,name VARCHAR,PRIMARY KEY (id))",
+		"CREATE SEQUENCE IF NOT EXISTS sequenced_events_id_seq",
+		"CREATE TABLE sequenced_events (id NUMBER(19,0) DEFAULT sequenced_events_id_seq.NEXTVAL,name VARCHAR,created_at TIMESTAMP_NTZ DEFAULT CURRENT_TIMESTAMP(),PRIMARY KEY (id)) CHANGE_TRACKING = TRUE",
+		"DROP TABLE IF EXISTS change_users",
+		"DROP SEQUENCE IF EXISTS change_users_id_seq",
+	}
+	if strings.Join(queries, "\n") != strings.Join(want, "\n") {
+		t.Errorf("queries:\n%s\nwant:\n%s", strings.Join(queries, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestColumnTypesOfSchema(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+	if _, err := db.Migrator().ColumnTypes(&migratedEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Table("analytics.migrated_events").Migrator().ColumnTypes(&migratedEvent{}); err != nil {
+		t.Fatal(err)
+	}
+
+	statements := d.statements
+	if !strings.Contains(statements[1].query, "table_schema = CURRENT_SCHEMA() AND table_name = ?") || statements[1].args[1] != "MIGRATED_EVENTS" {
+		t.Errorf("current schema: %s %v", statements[1].query, statements[1].args)
+	}
+	if !strings.Contains(statements[3].query, "table_schema = ? AND table_name = ?") || statements[3].args[1] != "ANALYTICS" || statements[3].args[2] != "MIGRATED_EVENTS" {
+		t.Errorf("qualified: %s %v", statements[3].query, statements[3].args)
+	}
+}
@@ -0,0 +1,336 @@
+package snowflake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+)
+
+// parquet physical types, encodings and codecs of the Parquet format (parquet.thrift)
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional = 1
+	parquetUTF8     = 0
+
+	parquetPlain = 0
+	parquetRLE   = 3
+	parquetGzip  = 2
+
+	parquetDataPage = 0
+)
+
+// parquetColumn a column of a Parquet file, with the values of every row (nil for NULL)
+type parquetColumn struct {
+	name   string
+	values []interface{}
+}
+
+// parquetType the physical type of the values of a column converted by arrayBindValue, text unless all of them are
+// bools, int64s, float64s or bytes
+func (c *parquetColumn) parquetType() int32 {
+	kind := int32(-1)
+	for _, value := range c.values {
+		var k int32
+		switch value.(type) {
+		case nil:
+			continue
+		case bool:
+			k = parquetBoolean
+		case int64:
+			k = parquetInt64
+		case float64:
+			k = parquetDouble
+		default:
+			k = parquetByteArray
+		}
+
+		if kind >= 0 && kind != k {
+			return parquetByteArray
+		}
+		kind = k
+	}
+
+	if kind < 0 {
+		return parquetByteArray
+	}
+	return kind
+}
+
+// encodeParquet a Parquet file of one row group holding the columns, all optional, every column in one GZIP
+// compressed data page with PLAIN values and RLE definition levels
+func encodeParquet(columns []parquetColumn) ([]byte, error) {
+	var (
+		buf  bytes.Buffer
+		rows = 0
+	)
+	if len(columns) > 0 {
+		rows = len(columns[0].values)
+	}
+	buf.WriteString("PAR1")
+
+	var (
+		elements = []func(w *thriftWriter){func(w *thriftWriter) {
+			w.string(4, "schema")
+			w.i32(5, int32(len(columns)))
+		}}
+		chunks    []func(w *thriftWriter)
+		totalSize int64
+	)
+	for _, column := range columns {
+		var (
+			column   = column
+			kind     = column.parquetType()
+			levels   = make([]bool, len(column.values))
+			page     bytes.Buffer
+			values   bytes.Buffer
+			booleans []bool
+		)
+		for idx, value := range column.values {
+			if value == nil {
+				continue
+			}
+			levels[idx] = true
+
+			switch kind {
+			case parquetBoolean:
+				booleans = append(booleans, value.(bool))
+			case parquetInt64:
+				_ = binary.Write(&values, binary.LittleEndian, value.(int64))
+			case parquetDouble:
+				_ = binary.Write(&values, binary.LittleEndian, math.Float64bits(value.(float64)))
+			default:
+				data, ok := value.([]byte)
+				if !ok {
+					data = []byte(loadText(value))
+				}
+				_ = binary.Write(&values, binary.LittleEndian, uint32(len(data)))
+				values.Write(data)
+			}
+		}
+		if kind == parquetBoolean {
+			values.Write(packBits(booleans))
+		}
+
+		encoded := encodeLevels(levels)
+		_ = binary.Write(&page, binary.LittleEndian, uint32(len(encoded)))
+		page.Write(encoded)
+		page.Write(values.Bytes())
+
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(page.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+
+		header := &thriftWriter{}
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(compressed.Len()))
+		header.structField(5, func(w *thriftWriter) {
+			w.i32(1, int32(len(column.values)))
+			w.i32(2, parquetPlain)
+			w.i32(3, parquetRLE)
+			w.i32(4, parquetRLE)
+		})
+		header.stop()
+
+		var (
+			offset           = int64(buf.Len())
+			uncompressedSize = int64(header.buf.Len() + page.Len())
+			compressedSize   = int64(header.buf.Len() + compressed.Len())
+		)
+		buf.Write(header.buf.Bytes())
+		buf.Write(compressed.Bytes())
+		totalSize += uncompressedSize
+
+		elements = append(elements, func(w *thriftWriter) {
+			w.i32(1, kind)
+			w.i32(3, parquetOptional)
+			w.string(4, column.name)
+			if kind == parquetByteArray && !isBytesColumn(column) {
+				w.i32(6, parquetUTF8)
+			}
+		})
+		chunks = append(chunks, func(w *thriftWriter) {
+			w.i64(2, offset)
+			w.structField(3, func(w *thriftWriter) {
+				w.i32(1, kind)
+				w.list(2, thriftI32, 2, func(w *thriftWriter) {
+					w.zigzag(parquetPlain)
+					w.zigzag(parquetRLE)
+				})
+				w.list(3, thriftBinary, 1, func(w *thriftWriter) {
+					w.binary([]byte(column.name))
+				})
+				w.i32(4, parquetGzip)
+				w.i64(5, int64(len(column.values)))
+				w.i64(6, uncompressedSize)
+				w.i64(7, compressedSize)
+				w.i64(9, offset)
+			})
+		})
+	}
+
+	footer := &thriftWriter{}
+	footer.i32(1, 1)
+	footer.structList(2, elements)
+	footer.i64(3, int64(rows))
+	footer.structList(4, []func(w *thriftWriter){func(w *thriftWriter) {
+		w.structList(1, chunks)
+		w.i64(2, totalSize)
+		w.i64(3, int64(rows))
+	}})
+	footer.string(6, "gorm-snowflake")
+	footer.stop()
+
+	buf.Write(footer.buf.Bytes())
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(footer.buf.Len()))
+	buf.WriteString("PAR1")
+	return buf.Bytes(), nil
+}
+
+// isBytesColumn if the values of a BYTE_ARRAY column are binaries rather than text
+func isBytesColumn(column parquetColumn) bool {
+	for _, value := range column.values {
+		if value != nil {
+			_, ok := value.([]byte)
+			return ok
+		}
+	}
+	return false
+}
+
+// encodeLevels definition levels of bit width 1 as RLE runs (RLE/bit-packing hybrid), true for non NULL values
+func encodeLevels(levels []bool) []byte {
+	var buf []byte
+	for start := 0; start < len(levels); {
+		end := start
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		buf = appendUvarint(buf, uint64(end-start)<<1)
+		if levels[start] {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		start = end
+	}
+	return buf
+}
+
+// packBits PLAIN booleans, one bit per value from the least significant
+func packBits(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for idx, value := range values {
+		if value {
+			packed[idx/8] |= 1 << uint(idx%8)
+		}
+	}
+	return packed
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var encoded [binary.MaxVarintLen64]byte
+	return append(buf, encoded[:binary.PutUvarint(encoded[:], v)]...)
+}
+
+// thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter a struct in the thrift compact protocol, the encoding of Parquet's metadata
+type thriftWriter struct {
+	buf     bytes.Buffer
+	fieldID int16
+}
+
+func (w *thriftWriter) field(id int16, kind byte) {
+	if delta := id - w.fieldID; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		w.buf.WriteByte(kind)
+		w.zigzag(int64(id))
+	}
+	w.fieldID = id
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf.Write(appendUvarint(nil, v))
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) binary(data []byte) {
+	w.varint(uint64(len(data)))
+	w.buf.Write(data)
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) string(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.binary([]byte(s))
+}
+
+// structField a nested struct, written by write
+func (w *thriftWriter) structField(id int16, write func(w *thriftWriter)) {
+	w.field(id, thriftStruct)
+	w.nested(write)
+}
+
+// list a list of size elements of kind, written by write without field headers
+func (w *thriftWriter) list(id int16, kind byte, size int, write func(w *thriftWriter)) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | kind)
+	} else {
+		w.buf.WriteByte(0xf0 | kind)
+		w.varint(uint64(size))
+	}
+	write(w)
+}
+
+// structList a list of structs, each written by its function
+func (w *thriftWriter) structList(id int16, structs []func(w *thriftWriter)) {
+	w.list(id, thriftStruct, len(structs), func(w *thriftWriter) {
+		for _, write := range structs {
+			w.nested(write)
+		}
+	})
+}
+
+// nested write a struct with its own field ids, ended by a stop
+func (w *thriftWriter) nested(write func(w *thriftWriter)) {
+	nested := &thriftWriter{}
+	write(nested)
+	nested.stop()
+	w.buf.Write(nested.buf.Bytes())
+}
+
+func (w *thriftWriter) stop() {
+	w.buf.WriteByte(0)
+}
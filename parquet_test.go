@@ -0,0 +1,61 @@
+package snowflake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeParquet(t *testing.T) {
+	file, err := encodeParquet([]parquetColumn{
+		{name: "id", values: []interface{}{int64(1), int64(2), nil}},
+		{name: "name", values: []interface{}{"a", nil, "ccc"}},
+		{name: "active", values: []interface{}{true, false, true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+		t.Fatalf("file isn't framed by PAR1: %q", file)
+	}
+	footer := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if footer <= 0 || footer > len(file)-12 {
+		t.Fatalf("footer length = %d of a %d bytes file", footer, len(file))
+	}
+	metadata := file[len(file)-8-footer : len(file)-8]
+	for _, name := range []string{"schema", "id", "name", "active"} {
+		if !bytes.Contains(metadata, []byte(name)) {
+			t.Errorf("metadata misses %q", name)
+		}
+	}
+}
+
+func TestParquetColumnTypes(t *testing.T) {
+	for _, test := range []struct {
+		values []interface{}
+		want   int32
+	}{
+		{[]interface{}{int64(1), nil}, parquetInt64},
+		{[]interface{}{1.5}, parquetDouble},
+		{[]interface{}{false, true}, parquetBoolean},
+		{[]interface{}{[]byte{1}}, parquetByteArray},
+		{[]interface{}{int64(1), "18446744073709551615"}, parquetByteArray},
+		{[]interface{}{nil, nil}, parquetByteArray},
+	} {
+		column := parquetColumn{values: test.values}
+		if got := column.parquetType(); got != test.want {
+			t.Errorf("type of %v = %d, want %d", test.values, got, test.want)
+		}
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	got := encodeLevels([]bool{true, true, false, true})
+	if want := []byte{2 << 1, 1, 1 << 1, 0, 1 << 1, 1}; !bytes.Equal(got, want) {
+		t.Errorf("levels = %v, want %v", got, want)
+	}
+	if got, want := packBits([]bool{true, false, true, true, false, false, false, false, true}), []byte{0x0d, 0x01}; !bytes.Equal(got, want) {
+		t.Errorf("bits = %v, want %v", got, want)
+	}
+}
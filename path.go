@@ -0,0 +1,91 @@
+package snowflake
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var castRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\s*\(\s*\d+\s*(,\s*\d+\s*)?\))?$`)
+
+// PathExpr a path into a semi-structured column, e.g. payload:customer.id::STRING
+type PathExpr struct {
+	column   string
+	elements []interface{}
+	cast     string
+}
+
+// Path the expression of the element of column found with keys (strings) and array indexes (ints), e.g.
+//
+//	snowflake.Path("payload", "customer", "id").As("STRING")  // payload:customer.id::STRING
+//	snowflake.Path("payload", "items", 0, "sku")              // payload:items[0].sku
+//	snowflake.Path("payload", "first name")                   // payload['first name']
+//
+// Use it in conditions and selects as an expression, e.g. db.Where("? = ?", path, "42"), or with Column in
+// clauses, e.g. db.Order(clause.OrderByColumn{Column: path.Column(), Desc: true}) and db.Group(path.String()).
+// Keys are case-sensitive, the column follows the dialect's quoting.
+func Path(column string, elements ...interface{}) PathExpr {
+	return PathExpr{column: column, elements: elements}
+}
+
+// As cast the element to a data type, e.g. STRING, NUMBER(10,2) or TIMESTAMP_TZ
+func (p PathExpr) As(dataType string) PathExpr {
+	p.cast = dataType
+	return p
+}
+
+// Column the path as a raw column, for clauses taking columns (clause.Eq, clause.OrderByColumn...)
+func (p PathExpr) Column() clause.Column {
+	return clause.Column{Name: p.String(), Raw: true}
+}
+
+func (p PathExpr) Build(builder clause.Builder) {
+	if p.cast != "" && !castRegexp.MatchString(p.cast) {
+		if stmt, ok := builder.(*gorm.Statement); ok {
+			_ = stmt.AddError(fmt.Errorf("snowflake: invalid path cast %q", p.cast))
+		}
+	}
+
+	builder.WriteQuoted(p.column)
+	builder.WriteString(p.path())
+}
+
+// String the path as SQL, with the column quoted like the dialect does
+func (p PathExpr) String() string {
+	var b strings.Builder
+	Dialector{}.QuoteTo(&b, p.column)
+	return b.String() + p.path()
+}
+
+// path the elements and cast following the column
+func (p PathExpr) path() string {
+	var b strings.Builder
+	for idx, element := range p.elements {
+		switch rv := reflect.ValueOf(element); rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			b.WriteString("[" + strconv.FormatInt(rv.Int(), 10) + "]")
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			b.WriteString("[" + strconv.FormatUint(rv.Uint(), 10) + "]")
+		default:
+			key := fmt.Sprint(element)
+			switch {
+			case !identifierRegexp.MatchString(key):
+				b.WriteString("['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key) + "']")
+			case idx == 0:
+				b.WriteString(":" + key)
+			default:
+				b.WriteString("." + key)
+			}
+		}
+	}
+
+	if p.cast != "" && castRegexp.MatchString(p.cast) {
+		b.WriteString("::" + p.cast)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package snowflake
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestPathString(t *testing.T) {
+	for _, test := range []struct {
+		path PathExpr
+		want string
+	}{
+		{Path("payload", "customer", "id"), "payload:customer.id"},
+		{Path("Payload", "customer", "id").As("STRING"), "payload:customer.id::STRING"},
+		{Path("payload", "items", 0, "sku"), "payload:items[0].sku"},
+		{Path("payload", 2, uint8(1)), "payload[2][1]"},
+		{Path("payload", "first name"), "payload['first name']"},
+		{Path("payload", "customer", "it's"), `payload:customer['it\'s']`},
+		{Path("payload", "back\\slash"), `payload['back\\slash']`},
+		{Path("payload", "amount").As("NUMBER(10, 2)"), "payload:amount::NUMBER(10, 2)"},
+		{Path("payload", "at").As("timestamp_tz"), "payload:at::timestamp_tz"},
+	} {
+		if got := test.path.String(); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestPathInQueries(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+	id := Path("payload", "customer", "id").As("STRING")
+
+	var rows []map[string]interface{}
+	stmt := dry.Table("events").Select("?", Path("payload", "items", 0, "first name")).
+		Where("? = ?", id, "42").
+		Order(clause.OrderByColumn{Column: id.Column(), Desc: true}).
+		Find(&rows).Statement
+	want := "SELECT payload:items[0]['first name'] FROM events WHERE payload:customer.id::STRING = ? ORDER BY payload:customer.id::STRING DESC"
+	if got := stmt.SQL.String(); got != want {
+		t.Errorf("\n got %s\nwant %s", got, want)
+	}
+	if !reflect.DeepEqual(stmt.Vars, []interface{}{"42"}) {
+		t.Errorf("vars = %#v", stmt.Vars)
+	}
+}
+
+func TestPathRefusesInvalidCasts(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+
+	for _, cast := range []string{"STRING; DROP TABLE events", "NUMBER(10,2", "VARCHAR(a)", "1NUMBER", ""} {
+		path := Path("payload", "id").As(cast)
+		if cast != "" && strings.Contains(path.String(), "::") {
+			t.Errorf("%q cast in %s", cast, path.String())
+		}
+
+		var rows []map[string]interface{}
+		err := dry.Table("events").Where("? = ?", path, "42").Find(&rows).Error
+		if cast == "" && err != nil {
+			t.Errorf("uncast path: %v", err)
+		} else if cast != "" && (err == nil || !strings.Contains(err.Error(), "invalid path cast")) {
+			t.Errorf("%q: %v", cast, err)
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package snowflake
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/snowflakedb/gosnowflake"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultConnectionName = "default"
+	envPrefix             = "SNOWFLAKE_"
+	envConnectionsPrefix  = "SNOWFLAKE_CONNECTIONS_"
+)
+
+// profileKeys connection keys understood in connections.toml and as environment overrides
+var profileKeys = []string{
+	"account", "user", "password", "database", "schema", "warehouse", "role", "region",
+	"host", "port", "protocol", "authenticator", "token", "token_file_path",
+	"private_key_file", "private_key_path", "login_timeout", "client_session_keep_alive",
+}
+
+// OpenProfile open a dialector from a named connection of the Snowflake CLI connections.toml,
+// with SNOWFLAKE_* environment overrides. An empty name resolves the default connection.
+func OpenProfile(name string) (gorm.Dialector, error) {
+	config, err := LoadProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	return New(*config), nil
+}
+
+// LoadProfile resolve a named connection into a Config, so it can be adjusted before New
+//
+// Lookup order (later wins):
+//   - [name] in connections.toml or [connections.name] in config.toml, found in SNOWFLAKE_HOME,
+//     ~/.snowflake or the OS config directory (or the TOML content of SNOWFLAKE_CONNECTIONS)
+//   - SNOWFLAKE_<KEY>, e.g. SNOWFLAKE_ACCOUNT
+//   - SNOWFLAKE_CONNECTIONS_<NAME>_<KEY>, e.g. SNOWFLAKE_CONNECTIONS_DEV_PASSWORD
+//
+// A named connection must be in a file or have SNOWFLAKE_CONNECTIONS_<NAME>_* variables,
+// SNOWFLAKE_<KEY> variables alone only make up the default connection when no name is set.
+func LoadProfile(name string) (*Config, error) {
+	configTOML, err := readTOMLFile(findConfigFile("config.toml"))
+	if err != nil {
+		return nil, err
+	}
+
+	named := true
+	if name == "" {
+		name = os.Getenv("SNOWFLAKE_DEFAULT_CONNECTION_NAME")
+	}
+	if name == "" {
+		if v, ok := configTOML.values[""]["default_connection_name"]; ok {
+			name = v
+		}
+	}
+	if name == "" {
+		name, named = defaultConnectionName, false
+	}
+
+	var connections *tomlTables
+	if content := os.Getenv("SNOWFLAKE_CONNECTIONS"); content != "" {
+		if connections, err = parseTOML(strings.NewReader(content)); err != nil {
+			return nil, fmt.Errorf("snowflake: invalid SNOWFLAKE_CONNECTIONS: %w", err)
+		}
+	} else if connections, err = readTOMLFile(findConfigFile("connections.toml")); err != nil {
+		return nil, err
+	}
+
+	values, found := map[string]string{}, false
+	for _, section := range []struct {
+		tables *tomlTables
+		name   string
+	}{{configTOML, "connections." + name}, {connections, name}} {
+		if table, ok := section.tables.table(section.name); ok {
+			found = true
+			for k, v := range table {
+				values[k] = v
+			}
+		}
+	}
+
+	// the generic variables override any connection, but only make up the unnamed default one,
+	// a misspelled name isn't silently connected with them
+	for _, key := range profileKeys {
+		if v, ok := os.LookupEnv(envPrefix + strings.ToUpper(key)); ok {
+			found = found || !named
+			values[key] = v
+		}
+	}
+	for _, key := range profileKeys {
+		if v, ok := os.LookupEnv(envConnectionsPrefix + strings.ToUpper(name) + "_" + strings.ToUpper(key)); ok {
+			found = true
+			values[key] = v
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("snowflake: connection %q not found in connections.toml or environment", name)
+	}
+
+	return profileConfig(name, values)
+}
+
+// profileConfig convert resolved connection values into a Config
+func profileConfig(name string, values map[string]string) (*Config, error) {
+	var (
+		sfConfig = &gosnowflake.Config{
+			Account:   values["account"],
+			User:      values["user"],
+			Password:  values["password"],
+			Database:  values["database"],
+			Schema:    values["schema"],
+			Warehouse: values["warehouse"],
+			Role:      values["role"],
+			Region:    values["region"],
+			Host:      values["host"],
+			Protocol:  values["protocol"],
+			Token:     values["token"],
+		}
+		config = &Config{DriverName: SnowflakeDriverName, Snowflake: sfConfig}
+		err    error
+	)
+
+	missing := func(key string) error {
+		return fmt.Errorf("snowflake: connection %q is missing %q", name, key)
+	}
+
+	if sfConfig.Account == "" {
+		return nil, missing("account")
+	}
+
+	// account identifiers may include the region (e.g. xy12345.us-east-1)
+	if idx := strings.Index(sfConfig.Account, "."); idx > 0 && sfConfig.Region == "" {
+		sfConfig.Region = sfConfig.Account[idx+1:]
+		sfConfig.Account = sfConfig.Account[:idx]
+	}
+
+	if v := values["port"]; v != "" {
+		if sfConfig.Port, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("snowflake: connection %q has invalid port %q", name, v)
+		}
+	}
+
+	if v := values["login_timeout"]; v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("snowflake: connection %q has invalid login_timeout %q", name, v)
+		}
+		sfConfig.LoginTimeout = time.Duration(seconds) * time.Second
+	}
+
+	if v := values["client_session_keep_alive"]; v != "" {
+		sfConfig.KeepSessionAlive, _ = strconv.ParseBool(v)
+	}
+
+	authenticator := strings.ToUpper(values["authenticator"])
+	switch {
+	case authenticator == "" || authenticator == "SNOWFLAKE":
+		sfConfig.Authenticator = gosnowflake.AuthTypeSnowflake
+	case authenticator == "SNOWFLAKE_JWT":
+		sfConfig.Authenticator = gosnowflake.AuthTypeJwt
+	case authenticator == "OAUTH":
+		sfConfig.Authenticator = gosnowflake.AuthTypeOAuth
+	case authenticator == "EXTERNALBROWSER":
+		sfConfig.Authenticator = gosnowflake.AuthTypeExternalBrowser
+	default:
+		return nil, fmt.Errorf("snowflake: connection %q has unsupported authenticator %q", name, values["authenticator"])
+	}
+
+	keyFile := values["private_key_file"]
+	if keyFile == "" {
+		keyFile = values["private_key_path"]
+	}
+	if keyFile != "" {
+		data, err := ioutil.ReadFile(expandHome(keyFile))
+		if err != nil {
+			return nil, fmt.Errorf("snowflake: connection %q: %w", name, err)
+		}
+		if sfConfig.PrivateKey, err = ParsePrivateKey(data); err != nil {
+			return nil, fmt.Errorf("snowflake: connection %q: %w", name, err)
+		}
+		if authenticator == "" {
+			sfConfig.Authenticator = gosnowflake.AuthTypeJwt
+		}
+	}
+
+	// token files are rotated by the platform, read them on every new connection
+	if tokenFile := values["token_file_path"]; tokenFile != "" && sfConfig.Token == "" {
+		tokenFile = expandHome(tokenFile)
+		config.TokenProvider = func(ctx context.Context) (string, error) {
+			data, err := ioutil.ReadFile(tokenFile)
+			return strings.TrimSpace(string(data)), err
+		}
+		sfConfig.Authenticator = gosnowflake.AuthTypeOAuth
+	}
+
+	switch sfConfig.Authenticator {
+	case gosnowflake.AuthTypeSnowflake:
+		if sfConfig.User == "" {
+			return nil, missing("user")
+		}
+		if sfConfig.Password == "" {
+			return nil, missing("password")
+		}
+	case gosnowflake.AuthTypeJwt:
+		if sfConfig.User == "" {
+			return nil, missing("user")
+		}
+		if sfConfig.PrivateKey == nil {
+			return nil, missing("private_key_file")
+		}
+	case gosnowflake.AuthTypeOAuth:
+		if sfConfig.Token == "" && config.TokenProvider == nil {
+			return nil, missing("token")
+		}
+	case gosnowflake.AuthTypeExternalBrowser:
+		if sfConfig.User == "" {
+			return nil, missing("user")
+		}
+	}
+
+	return config, nil
+}
+
+// findConfigFile return the first existing snowflake config file, or empty when none exists
+func findConfigFile(name string) string {
+	var dirs []string
+	if home := os.Getenv("SNOWFLAKE_HOME"); home != "" {
+		dirs = append(dirs, expandHome(home))
+	} else {
+		if home, err := os.UserHomeDir(); err == nil {
+			dirs = append(dirs, filepath.Join(home, ".snowflake"))
+		}
+
+		switch runtime.GOOS {
+		case "linux":
+			if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+				dirs = append(dirs, filepath.Join(dir, "snowflake"))
+			} else if home, err := os.UserHomeDir(); err == nil {
+				dirs = append(dirs, filepath.Join(home, ".config", "snowflake"))
+			}
+		default:
+			if dir, err := os.UserConfigDir(); err == nil {
+				dirs = append(dirs, filepath.Join(dir, "snowflake"))
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// tomlTables the values of the tables of a TOML file by table and key, as text
+type tomlTables struct {
+	values map[string]map[string]string
+}
+
+// table the values of the table name
+func (t *tomlTables) table(name string) (map[string]string, bool) {
+	values, ok := t.values[name]
+	return values, ok
+}
+
+func readTOMLFile(path string) (*tomlTables, error) {
+	if path == "" {
+		return parseTOML(strings.NewReader(""))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tables, err := parseTOML(f)
+	if err != nil {
+		return nil, fmt.Errorf("snowflake: %s: %w", path, err)
+	}
+	return tables, nil
+}
+
+var (
+	bareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+	integerRegexp = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+)
+
+// parseTOML parse the subset of TOML used by snowflake connections, and fail on anything else:
+//   - blank lines and # comments
+//   - [table] headers, their names made of bare or quoted keys separated by dots, each table defined once
+//   - key = value pairs of a bare or quoted key, each defined once, whose value is a basic string ("..." with the
+//     TOML escapes), a literal string ('...'), a decimal integer (underscores allowed) or a boolean
+//
+// Arrays, inline tables, arrays of tables, multi-line strings, floats, dates and dotted keys aren't supported.
+// Keys before any table are returned under "".
+func parseTOML(r io.Reader) (*tomlTables, error) {
+	var (
+		tables  = &tomlTables{values: map[string]map[string]string{"": {}}}
+		defined = map[string]bool{}
+		current = ""
+		scanner = bufio.NewScanner(r)
+		lineNum = 0
+	)
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			parts, err := parseTOMLTable(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			for idx := range parts {
+				if _, ok := tables.values[strings.Join(parts[:idx], ".")][parts[idx]]; ok {
+					return nil, fmt.Errorf("line %d: key %q is not a table", lineNum, strings.Join(parts[:idx+1], "."))
+				}
+			}
+
+			current = strings.Join(parts, ".")
+			if defined[current] {
+				return nil, fmt.Errorf("line %d: table [%s] defined twice", lineNum, current)
+			}
+			defined[current] = true
+			if _, ok := tables.values[current]; !ok {
+				tables.values[current] = map[string]string{}
+			}
+			continue
+		}
+
+		key, value, err := parseTOMLKeyValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		path := key
+		if current != "" {
+			path = current + "." + key
+		}
+		if _, ok := tables.values[current][key]; ok || tables.values[path] != nil {
+			return nil, fmt.Errorf("line %d: key %q defined twice", lineNum, path)
+		}
+		tables.values[current][key] = value
+	}
+
+	return tables, scanner.Err()
+}
+
+// parseTOMLTable the keys of the name of a [table] header
+func parseTOMLTable(line string) (parts []string, err error) {
+	if strings.HasPrefix(line, "[[") {
+		return nil, fmt.Errorf("arrays of tables aren't supported: %s", line)
+	} else if !strings.HasSuffix(line, "]") {
+		return nil, fmt.Errorf("invalid table header %q", line)
+	}
+
+	rest := strings.TrimSpace(line[1 : len(line)-1])
+	for {
+		part, next, err := parseTOMLKey(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid table header %q", line)
+		}
+		parts = append(parts, part)
+
+		if rest = strings.TrimSpace(next); rest == "" {
+			return parts, nil
+		} else if rest[0] != '.' {
+			return nil, fmt.Errorf("invalid table header %q", line)
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+}
+
+// parseTOMLKeyValue the key and the text of the value of a key = value line
+func parseTOMLKeyValue(line string) (key, value string, err error) {
+	key, rest, err := parseTOMLKey(line)
+	if err != nil {
+		return "", "", err
+	}
+
+	switch rest = strings.TrimSpace(rest); {
+	case strings.HasPrefix(rest, "."):
+		return "", "", fmt.Errorf("dotted keys aren't supported: %s", line)
+	case !strings.HasPrefix(rest, "="):
+		return "", "", fmt.Errorf("expected key = value: %s", line)
+	}
+
+	if value, err = parseTOMLValue(strings.TrimSpace(rest[1:])); err != nil {
+		return "", "", fmt.Errorf("%s: %w", key, err)
+	}
+	return key, value, nil
+}
+
+// parseTOMLKey the bare or quoted key at the start of s, and what follows it
+func parseTOMLKey(s string) (key, rest string, err error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		return parseTOMLString(s)
+	}
+
+	if key = bareKeyRegexp.FindString(s); key == "" {
+		return "", "", fmt.Errorf("invalid key %q", s)
+	}
+	return key, s[len(key):], nil
+}
+
+// parseTOMLValue the text of a string, integer or boolean value
+func parseTOMLValue(value string) (string, error) {
+	switch {
+	case value == "":
+		return "", errors.New("missing value")
+	case strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''"):
+		return "", fmt.Errorf("multi-line strings aren't supported: %s", value)
+	case strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'"):
+		v, rest, err := parseTOMLString(value)
+		if err != nil {
+			return "", err
+		} else if rest = strings.TrimSpace(rest); rest != "" {
+			return "", fmt.Errorf("unexpected %s after the string", rest)
+		}
+		return v, nil
+	case value == "true" || value == "false":
+		return value, nil
+	case integerRegexp.MatchString(value):
+		return strings.TrimPrefix(strings.ReplaceAll(value, "_", ""), "+"), nil
+	case strings.HasPrefix(value, "["):
+		return "", fmt.Errorf("arrays aren't supported: %s", value)
+	case strings.HasPrefix(value, "{"):
+		return "", fmt.Errorf("inline tables aren't supported: %s", value)
+	}
+	return "", fmt.Errorf("unsupported value %s, only strings, decimal integers and booleans are", value)
+}
+
+// parseTOMLString the basic or literal string at the start of s, and what follows it
+func parseTOMLString(s string) (value, rest string, err error) {
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return b.String(), s[i+1:], nil
+		} else if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+
+		if i++; i == len(s) {
+			break
+		}
+		switch e := s[i]; e {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(e)
+		case 'u', 'U':
+			size := 4
+			if e == 'U' {
+				size = 8
+			}
+			if i+size >= len(s) {
+				return "", "", fmt.Errorf("invalid escape in string %s", s)
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", "", fmt.Errorf("invalid escape in string %s", s)
+			}
+			b.WriteRune(rune(code))
+			i += size
+		default:
+			return "", "", fmt.Errorf("invalid escape \\%c in string %s", e, s)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", s)
+}
+
+// stripTOMLComment remove a trailing # comment outside of quoted strings
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
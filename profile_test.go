@@ -0,0 +1,205 @@
+package snowflake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setenv set an environment variable for the test, restoring it after
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	restoreEnv(t, key)
+	os.Setenv(key, value)
+}
+
+// unsetenv unset an environment variable for the test, restoring it after
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	restoreEnv(t, key)
+	os.Unsetenv(key)
+}
+
+func restoreEnv(t *testing.T, key string) {
+	previous, ok := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// isolateProfiles point the profile lookup to an empty directory, without SNOWFLAKE_* variables
+func isolateProfiles(t *testing.T) string {
+	t.Helper()
+	for _, env := range os.Environ() {
+		if key := env[:strings.Index(env, "=")]; strings.HasPrefix(key, envPrefix) {
+			unsetenv(t, key)
+		}
+	}
+
+	dir := t.TempDir()
+	setenv(t, "SNOWFLAKE_HOME", dir)
+	return dir
+}
+
+func TestParseTOML(t *testing.T) {
+	tables, err := parseTOML(strings.NewReader(`
+# the default connection
+default_connection_name = "dev"
+
+[cli.logs]
+save_logs = true
+level = 'info' # comment
+
+[connections.dev]
+account = "xy12345" # comment
+"user" = "al\"ice\u00e9\t#"
+'password' = 'C:\secret#1'
+port = 4_443
+login_timeout = +30
+client_session_keep_alive = false
+
+[ connections . "prod.eu" ]
+account = "ab12345"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := tables.values[""]["default_connection_name"]; got != "dev" {
+		t.Errorf("default_connection_name = %q", got)
+	}
+	if logs, _ := tables.table("cli.logs"); logs["save_logs"] != "true" || logs["level"] != "info" {
+		t.Errorf("cli.logs = %v", logs)
+	}
+
+	want := map[string]string{
+		"account":                   "xy12345",
+		"user":                      "al\"ice\u00e9\t#",
+		"password":                  `C:\secret#1`,
+		"port":                      "4443",
+		"login_timeout":             "30",
+		"client_session_keep_alive": "false",
+	}
+	if values, ok := tables.table("connections.dev"); !ok || !reflect.DeepEqual(values, want) {
+		t.Errorf("connections.dev = %v", values)
+	}
+	if values, ok := tables.table("connections.prod.eu"); !ok || values["account"] != "ab12345" {
+		t.Errorf("connections.prod.eu = %v", values)
+	}
+}
+
+func TestParseTOMLRefusesUnsupportedSyntax(t *testing.T) {
+	for content, want := range map[string]string{
+		"[dev":                                "invalid table header",
+		"[dev]]":                              "invalid table header",
+		"[dev prod]":                          "invalid table header",
+		"[[connections]]":                     "arrays of tables",
+		"[dev]\n[dev]":                        "defined twice",
+		"[dev]\naccount = 'a'\naccount = 'b'": "defined twice",
+		"dev = 'a'\n[dev]":                    "not a table",
+		"[dev.x]\n[dev]\nx = 1":               "defined twice",
+		"account":                             "expected key = value",
+		"account = ":                          "missing value",
+		"acc ount = 'a'":                      "expected key = value",
+		"dev.account = 'a'":                   "dotted keys",
+		"account = 'xy":                       "unterminated string",
+		`account = "xy`:                       "unterminated string",
+		`account = "xy" "z"`:                  "after the string",
+		`account = "\x41"`:                    "invalid escape",
+		`account = "\ud800"`:                  "invalid escape",
+		"account = \"\"\"\nxy\n\"\"\"":        "multi-line strings",
+		"account = '''xy'''":                  "multi-line strings",
+		"list = ['a']":                        "arrays",
+		"list = [\n'a',\n]":                   "arrays",
+		"inline = { a = 1 }":                  "inline tables",
+		"level = 1.5":                         "unsupported value",
+		"port = 0x1bb":                        "unsupported value",
+		"port = 0443":                         "unsupported value",
+		"at = 1979-05-27":                     "unsupported value",
+		"on = yes":                            "unsupported value",
+	} {
+		if _, err := parseTOML(strings.NewReader(content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error = %v, want %q", content, err, want)
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir := isolateProfiles(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte(`
+[cli.logs]
+level = "info"
+
+[connections.dev]
+account = "xy12345.us-east-1"
+user = "alice"
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "SNOWFLAKE_PASSWORD", "secret")
+
+	config, err := LoadProfile("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := config.Snowflake
+	if sf.Account != "xy12345" || sf.Region != "us-east-1" || sf.User != "alice" || sf.Password != "secret" {
+		t.Errorf("config = %+v", sf)
+	}
+
+	if _, err := LoadProfile("dve"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("misspelled connection: %v", err)
+	}
+
+	setenv(t, "SNOWFLAKE_CONNECTIONS_PROD_ACCOUNT", "ab12345")
+	setenv(t, "SNOWFLAKE_CONNECTIONS_PROD_USER", "bob")
+	if config, err = LoadProfile("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if config.Snowflake.Account != "ab12345" || config.Snowflake.Password != "secret" {
+		t.Errorf("config = %+v", config.Snowflake)
+	}
+}
+
+func TestLoadProfileDefaultFromEnvironment(t *testing.T) {
+	isolateProfiles(t)
+	setenv(t, "SNOWFLAKE_ACCOUNT", "xy12345")
+	setenv(t, "SNOWFLAKE_USER", "alice")
+	setenv(t, "SNOWFLAKE_PASSWORD", "secret")
+
+	config, err := LoadProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Snowflake.Account != "xy12345" {
+		t.Errorf("config = %+v", config.Snowflake)
+	}
+
+	setenv(t, "SNOWFLAKE_DEFAULT_CONNECTION_NAME", "dev")
+	if _, err := LoadProfile(""); err == nil {
+		t.Error("named default connection made up of SNOWFLAKE_<KEY> variables")
+	}
+}
+
+func TestLoadProfileRefusesUnsupportedTOML(t *testing.T) {
+	dir := isolateProfiles(t)
+	path := filepath.Join(dir, "connections.toml")
+	if err := ioutil.WriteFile(path, []byte("[dev]\naccount = \"xy12345\"\nport = 443.0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProfile("dev"); err == nil || !strings.Contains(err.Error(), path+": line 3: port: unsupported value 443.0") {
+		t.Errorf("error = %v", err)
+	}
+
+	setenv(t, "SNOWFLAKE_CONNECTIONS", "dev = { account = \"xy12345\" }")
+	if _, err := LoadProfile("dev"); err == nil || !strings.Contains(err.Error(), "invalid SNOWFLAKE_CONNECTIONS: line 1: dev: inline tables aren't supported") {
+		t.Errorf("error = %v", err)
+	}
+}
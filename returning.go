@@ -0,0 +1,256 @@
+package snowflake
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// returningFields the schema fields requested by clause.Returning, all fields for none or "*"
+func returningFields(db *gorm.DB) ([]*schema.Field, bool) {
+	c, ok := db.Statement.Clauses["RETURNING"]
+	if !ok || db.Statement.Schema == nil {
+		return nil, false
+	}
+
+	returning, _ := c.Expression.(clause.Returning)
+	if len(returning.Columns) == 0 || (len(returning.Columns) == 1 && returning.Columns[0].Name == "*") {
+		fields := make([]*schema.Field, 0, len(db.Statement.Schema.DBNames))
+		for _, dbName := range db.Statement.Schema.DBNames {
+			fields = append(fields, db.Statement.Schema.FieldsByDBName[dbName])
+		}
+		return fields, true
+	}
+
+	fields := make([]*schema.Field, 0, len(returning.Columns))
+	for _, column := range returning.Columns {
+		if field := lookUpField(db.Statement.Schema, column.Name); field != nil {
+			fields = append(fields, field)
+		}
+	}
+	return fields, true
+}
+
+// lookUpField by name or column name, ignoring case as snowflake does for unquoted identifiers
+func lookUpField(sch *schema.Schema, name string) *schema.Field {
+	if field := sch.LookUpField(name); field != nil {
+		return field
+	}
+
+	for _, field := range sch.Fields {
+		if field.DBName != "" && strings.EqualFold(field.DBName, name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// Update gorm update callback, emulating clause.Returning by reading the updated rows back with CHANGES,
+// binding the times of zoned, DATE and TIME columns as text (see bindTime) and the values of registered types
+// with their bind expressions
+func Update(config *callbacks.Config) func(db *gorm.DB) {
+	update := withReturning(callbacks.Update(config))
+	return func(db *gorm.DB) {
+		if _, ok := db.Statement.Clauses["SET"]; !ok && db.Error == nil && db.Statement.SQL.Len() == 0 &&
+			(bindsTimes(db) || configOf(db).Types.bindsExprs(db.Statement.Schema)) {
+			// the assignments gorm would build, with their values converted
+			if set := callbacks.ConvertToAssignments(db.Statement); len(set) != 0 {
+				bindTimeAssignments(db, set)
+				bindExprAssignments(db, set)
+				defer delete(db.Statement.Clauses, "SET")
+				db.Statement.AddClause(set)
+			}
+		}
+		update(db)
+	}
+}
+
+// Delete gorm delete callback, emulating clause.Returning by reading the deleted rows back with CHANGES
+func Delete(config *callbacks.Config) func(db *gorm.DB) {
+	return withReturning(callbacks.Delete(config))
+}
+
+// withReturning run the DML on a pinned connection, then select its changes:
+// the new version of updated rows (also soft deletes) and the deleted rows
+func withReturning(exec func(db *gorm.DB)) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		fields, ok := returningFields(db)
+		if !ok || db.DryRun || db.Error != nil {
+			exec(db)
+			return
+		}
+
+		connPool := db.Statement.ConnPool
+		if pooler, ok := connPool.(connPooler); ok {
+			conn, err := pooler.Conn(db.Statement.Context)
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			defer conn.Close()
+
+			db.Statement.ConnPool = conn
+			defer func() { db.Statement.ConnPool = connPool }()
+		}
+
+		if err := checkChangeTracking(db, db.Statement.ConnPool); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+
+		// like gorm, update the models in place when specific columns are returned, matching them by primary key
+		mode, byKey := gorm.ScanUpdate, false
+		if returning, _ := db.Statement.Clauses["RETURNING"].Expression.(clause.Returning); len(returning.Columns) == 0 || returning.Columns[0].Name == "*" {
+			mode = 0
+		} else if rv := db.Statement.ReflectValue; (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Len() > 1 {
+			if len(db.Statement.Schema.PrimaryFields) == 0 {
+				_ = db.AddError(fmt.Errorf("snowflake: clause.Returning columns into several %s need a primary key to match the changed rows", db.Statement.Schema.Name))
+				return
+			}
+			byKey = true
+			fields = withFields(fields, db.Statement.Schema.PrimaryFields)
+		}
+
+		exec(db)
+		if db.Error != nil || len(fields) == 0 {
+			return
+		}
+
+		db.Statement.SQL.Reset()
+		db.Statement.Vars = nil
+		db.Statement.WriteString("SELECT ")
+		for idx, field := range fields {
+			if idx > 0 {
+				db.Statement.WriteByte(',')
+			}
+			db.Statement.WriteQuoted(field.DBName)
+		}
+		db.Statement.WriteString(" FROM ")
+		db.Statement.WriteQuoted(db.Statement.Table)
+		db.Statement.WriteString(" CHANGES(INFORMATION => DEFAULT)" + changesOfLastQuery)
+		db.Statement.WriteString(" WHERE METADATA$ACTION = 'INSERT' OR NOT METADATA$ISUPDATE;")
+
+		rows, err := db.Statement.ConnPool.QueryContext(db.Statement.Context, db.Statement.SQL.String())
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+
+		if byKey {
+			scanReturningByKey(db, scanRows(db, rows), fields)
+			_ = db.AddError(rows.Close())
+			return
+		}
+
+		// scan into the updated models rather than the update values
+		dest := db.Statement.Dest
+		if db.Statement.ReflectValue.CanAddr() {
+			db.Statement.Dest = db.Statement.ReflectValue.Addr().Interface()
+		}
+		gorm.Scan(scanRows(db, rows), db, mode)
+		db.Statement.Dest = dest
+		_ = db.AddError(rows.Close())
+	}
+}
+
+// withFields fields and the keys missing from them
+func withFields(fields []*schema.Field, keys []*schema.Field) []*schema.Field {
+	selected := map[string]bool{}
+	for _, field := range fields {
+		selected[field.DBName] = true
+	}
+	for _, field := range keys {
+		if !selected[field.DBName] {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// scanReturningByKey scan the changed rows into new models, and set their fields on the models with the same
+// primary key, CHANGES returns the rows in no particular order
+func scanReturningByKey(db *gorm.DB, rows gorm.Rows, fields []*schema.Field) {
+	var (
+		sch          = db.Statement.Schema
+		ctx          = db.Statement.Context
+		returned     = reflect.New(reflect.SliceOf(sch.ModelType))
+		dest         = db.Statement.Dest
+		reflectValue = db.Statement.ReflectValue
+	)
+
+	db.Statement.Dest, db.Statement.ReflectValue = returned.Interface(), returned.Elem()
+	gorm.Scan(rows, db, 0)
+	db.Statement.Dest, db.Statement.ReflectValue = dest, reflectValue
+	if db.Error != nil {
+		return
+	}
+
+	keyOf := func(model reflect.Value) string {
+		key := ""
+		for _, field := range sch.PrimaryFields {
+			v, _ := field.ValueOf(ctx, model)
+			key += fmt.Sprintf("%v\x00", v)
+		}
+		return key
+	}
+
+	index := map[string]reflect.Value{}
+	for i := 0; i < reflectValue.Len(); i++ {
+		model := reflect.Indirect(reflectValue.Index(i))
+		if model.Kind() == reflect.Struct {
+			index[keyOf(model)] = model
+		}
+	}
+
+	for i := 0; i < returned.Elem().Len(); i++ {
+		row := returned.Elem().Index(i)
+		model, ok := index[keyOf(row)]
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			v, _ := field.ValueOf(ctx, row)
+			if err := field.Set(ctx, model, v); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	}
+}
+
+// checkChangeTracking check the table has CHANGE_TRACKING before its changes are read back for clause.Returning,
+// CreateTable leaves it out for tables with sequence backed IDs and no other database defaults
+func checkChangeTracking(db *gorm.DB, connPool gorm.ConnPool) error {
+	if db.Statement.Schema == nil || needsChangeTracking(configOf(db), db.Statement.Schema) {
+		return nil
+	}
+
+	table, show := db.Statement.Table, "SHOW TABLES LIKE "
+	if idx := strings.LastIndex(table, "."); idx >= 0 {
+		show += quoteString(table[idx+1:]) + " IN SCHEMA " + table[:idx]
+		table = table[idx+1:]
+	} else {
+		show += quoteString(table)
+	}
+
+	rows, err := connPool.QueryContext(db.Statement.Context, show)
+	if err != nil {
+		return err
+	}
+	records, err := scanRecords(rows)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if strings.EqualFold(recordString(record["name"]), table) && strings.EqualFold(recordString(record["change_tracking"]), "ON") {
+			return nil
+		}
+	}
+	return fmt.Errorf("snowflake: clause.Returning reads the changes of %s, it needs CHANGE_TRACKING = TRUE", db.Statement.Table)
+}
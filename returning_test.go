@@ -0,0 +1,63 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type returningItem struct {
+	ID      int64
+	Name    string
+	Version int
+}
+
+func TestReturningMatchesRowsByKey(t *testing.T) {
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		if strings.Contains(statement.query, "CHANGES(") {
+			// in no particular order
+			return &fakeRows{columns: []string{"version", "id"}, values: [][]driver.Value{{"8", "2"}, {"4", "1"}}}, nil
+		}
+		return nil, nil
+	})
+
+	items := []returningItem{{ID: 1, Name: "a", Version: 3}, {ID: 2, Name: "b", Version: 7}}
+	err := db.Model(&items).Clauses(clause.Returning{Columns: []clause.Column{{Name: "version"}}}).
+		Update("version", gorm.Expr("version + 1")).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := d.queries()
+	assertQueries(t, queries, "UPDATE returning_items SET version=version + 1 WHERE",
+		"SELECT version,id FROM returning_items CHANGES(INFORMATION => DEFAULT) BEFORE(statement=>LAST_QUERY_ID()) END(statement=>LAST_QUERY_ID())")
+	if items[0].Version != 4 || items[1].Version != 8 || items[0].Name != "a" {
+		t.Errorf("items = %+v", items)
+	}
+}
+
+func TestReturningNeedsChangeTracking(t *testing.T) {
+	changeTracking := "OFF"
+	db, d := openFake(t, Config{IDStrategy: IDSequence}, func(statement fakeStatement) (*fakeRows, error) {
+		if strings.HasPrefix(statement.query, "SHOW TABLES") {
+			return fakeRow([]string{"name", "change_tracking"}, "RETURNING_ITEMS", changeTracking), nil
+		}
+		return nil, nil
+	})
+
+	item := returningItem{ID: 1}
+	err := db.Model(&item).Clauses(clause.Returning{}).Update("name", "c").Error
+	if err == nil || !strings.Contains(err.Error(), "CHANGE_TRACKING") {
+		t.Fatalf("error = %v", err)
+	}
+	assertQueries(t, d.queries(), "SHOW TABLES LIKE 'returning_items'")
+
+	changeTracking = "ON"
+	if err := db.Model(&item).Clauses(clause.Returning{}).Update("name", "c").Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries()[1:], "SHOW TABLES LIKE 'returning_items'", "UPDATE returning_items SET", "SELECT ")
+}
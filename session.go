@@ -0,0 +1,586 @@
+package snowflake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snowflakedb/gosnowflake"
+	"gorm.io/gorm"
+)
+
+const sessionStateKey = "snowflake:session_state"
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// errUnrestorable the session can't be restored, e.g. there was no warehouse to go back to
+var errUnrestorable = errors.New("snowflake: the session can't be restored")
+
+type sessionParametersKey struct{}
+
+type sessionAppliedKey struct{}
+
+type warehouseKey struct{}
+
+type roleKey struct{}
+
+type secondaryRolesKey struct{}
+
+// pinnedConn the connection pinned by WithSession, discarded instead of returned to the pool when a statement
+// couldn't restore its session
+type pinnedConn struct {
+	*sql.Conn
+	discard bool
+}
+
+const (
+	warehouseSettingKey      = "snowflake:warehouse"
+	roleSettingKey           = "snowflake:role"
+	secondaryRolesSettingKey = "snowflake:secondary_roles"
+)
+
+// Warehouser can be implemented by models to run their statements on a dedicated warehouse
+type Warehouser interface {
+	SnowflakeWarehouse() string
+}
+
+// WithSessionParameters return a context that overrides session parameters (e.g. QUERY_TAG, TIMEZONE)
+// for statements executed with it, e.g. db.WithContext(snowflake.WithSessionParameters(ctx, params)).
+// The parameters are set on a pinned connection and restored to their session values once the statement finishes.
+func WithSessionParameters(ctx context.Context, parameters map[string]string) context.Context {
+	merged := map[string]string{}
+	if existing, ok := ctx.Value(sessionParametersKey{}).(map[string]string); ok {
+		for k, v := range existing {
+			merged[k] = v
+		}
+	}
+	for k, v := range parameters {
+		merged[strings.ToUpper(k)] = v
+	}
+	return context.WithValue(ctx, sessionParametersKey{}, merged)
+}
+
+// WithWarehouse return a context whose statements run on the given warehouse
+func WithWarehouse(ctx context.Context, warehouse string) context.Context {
+	return context.WithValue(ctx, warehouseKey{}, warehouse)
+}
+
+// UseWarehouse scope running the statement on the given warehouse, e.g. db.Scopes(snowflake.UseWarehouse("XL_WH")).Find(&reports)
+//
+// The warehouse is chosen from UseWarehouse, then WithWarehouse, then the model's SnowflakeWarehouse.
+func UseWarehouse(warehouse string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(warehouseSettingKey, warehouse)
+	}
+}
+
+// WithRole return a context whose statements run under the given role
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// UseRole scope running the statement under the given role, the original role is restored afterwards
+func UseRole(role string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(roleSettingKey, role)
+	}
+}
+
+// WithSecondaryRoles return a context whose statements run with the given secondary roles,
+// either "ALL", a list of roles, or none for NONE
+func WithSecondaryRoles(ctx context.Context, roles ...string) context.Context {
+	return context.WithValue(ctx, secondaryRolesKey{}, roles)
+}
+
+// UseSecondaryRoles scope running the statement with the given secondary roles, see WithSecondaryRoles
+func UseSecondaryRoles(roles ...string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(secondaryRolesSettingKey, roles)
+	}
+}
+
+// connPooler is implemented by pools (*sql.DB) that hand out a different connection per statement
+type connPooler interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// WithSession run fc with all its statements on a single connection, for session scoped features
+// (LAST_QUERY_ID(), temporary tables, USE statements, session variables), e.g.
+//
+//	err := snowflake.WithSession(db, func(tx *gorm.DB) error {
+//		tx.Exec("CREATE TEMPORARY TABLE tmp_ids (id NUMBER)")
+//		return tx.Raw("SELECT id FROM tmp_ids").Scan(&ids).Error
+//	})
+//
+// The connection is returned to the pool when fc returns, or discarded when a statement couldn't restore its session,
+// transactions can be started inside fc. When db is already pinned (in a transaction or WithSession), fc runs on it
+// directly.
+func WithSession(db *gorm.DB, fc func(tx *gorm.DB) error) (err error) {
+	pooler, ok := db.Statement.ConnPool.(connPooler)
+	if !ok {
+		return fc(db)
+	}
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	conn, err := pooler.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	pinned := &pinnedConn{Conn: conn}
+	defer func() {
+		if pinned.discard {
+			_ = discardConn(conn)
+		} else if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	tx := db.Session(&gorm.Session{Context: ctx})
+	tx.Statement.ConnPool = pinned
+	return fc(tx)
+}
+
+// sessionOverride a statement changing the session, with how to restore it
+type sessionOverride struct {
+	apply string
+	// restore read the session on conn before apply and return the statement restoring it, empty when the session
+	// is already in the requested state, errUnrestorable when the connection has to be discarded instead
+	restore func(ctx context.Context, conn gorm.ConnPool) (string, error)
+}
+
+// sessionState pinned connection and the statements restoring its session once the statement finishes
+type sessionState struct {
+	pool     gorm.ConnPool
+	ctx      context.Context
+	conn     *sql.Conn
+	restores []string
+	// discard the connection instead of restoring it
+	discard bool
+}
+
+// registerSessionCallbacks apply session overrides around every processor
+func registerSessionCallbacks(db *gorm.DB) {
+	_ = db.Callback().Create().Before("*").Register("snowflake:apply_session", applySession)
+	_ = db.Callback().Create().After("*").Register("snowflake:restore_session", restoreSession(false))
+	_ = db.Callback().Query().Before("*").Register("snowflake:apply_session", applySession)
+	_ = db.Callback().Query().After("*").Register("snowflake:restore_session", restoreSession(false))
+	_ = db.Callback().Update().Before("*").Register("snowflake:apply_session", applySession)
+	_ = db.Callback().Update().After("*").Register("snowflake:restore_session", restoreSession(false))
+	_ = db.Callback().Delete().Before("*").Register("snowflake:apply_session", applySession)
+	_ = db.Callback().Delete().After("*").Register("snowflake:restore_session", restoreSession(false))
+	_ = db.Callback().Raw().Before("*").Register("snowflake:apply_session", applySession)
+	_ = db.Callback().Raw().After("*").Register("snowflake:restore_session", restoreSession(false))
+	// rows are still open when the row processor finishes, the connection is released once they are closed
+	_ = db.Callback().Row().Before("*").Register("snowflake:apply_session", applySession)
+	_ = db.Callback().Row().After("*").Register("snowflake:restore_session", restoreSession(true))
+}
+
+// sessionOverrides return the session overrides requested for db
+func sessionOverrides(db *gorm.DB) (overrides []sessionOverride, err error) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		return nil, nil
+	}
+
+	// the role goes first, it decides which warehouses can be used
+	if role := settingOf(db, roleSettingKey, roleKey{}); role != "" {
+		overrides = append(overrides, sessionOverride{
+			apply: "USE ROLE " + quoteIdentifier(role),
+			restore: restoreCurrent("SELECT CURRENT_ROLE()", func(value string) (string, error) {
+				if strings.EqualFold(value, role) {
+					return "", nil
+				}
+				return "USE ROLE " + quoteName(value), nil
+			}),
+		})
+	}
+
+	if roles, ok := secondaryRolesOf(db); ok {
+		apply := "USE SECONDARY ROLES " + secondaryRolesSQL(roles)
+		overrides = append(overrides, sessionOverride{
+			apply: apply,
+			restore: restoreCurrent("SELECT CURRENT_SECONDARY_ROLES()", func(value string) (string, error) {
+				restore, err := restoreSecondaryRoles(value)
+				if err != nil || restore == apply {
+					return "", err
+				}
+				return restore, nil
+			}),
+		})
+	}
+
+	if warehouse := warehouseOf(db); warehouse != "" {
+		overrides = append(overrides, sessionOverride{
+			apply: "USE WAREHOUSE " + quoteIdentifier(warehouse),
+			restore: restoreCurrent("SELECT CURRENT_WAREHOUSE()", func(value string) (string, error) {
+				if strings.EqualFold(value, warehouse) {
+					return "", nil
+				}
+				return "USE WAREHOUSE " + quoteName(value), nil
+			}),
+		})
+	}
+
+	if parameters, ok := ctx.Value(sessionParametersKey{}).(map[string]string); ok {
+		names := make([]string, 0, len(parameters))
+		for name := range parameters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if !identifierRegexp.MatchString(name) {
+				return nil, fmt.Errorf("snowflake: invalid session parameter %q", name)
+			}
+
+			overrides = append(overrides, sessionOverride{
+				apply:   "ALTER SESSION SET " + name + " = " + sessionValue(parameters[name]),
+				restore: restoreParameter(name),
+			})
+		}
+	}
+
+	return
+}
+
+// settingOf return a string requested by scope setting, then context
+func settingOf(db *gorm.DB, settingKey string, contextKey interface{}) string {
+	if v, ok := db.Get(settingKey); ok {
+		if value, ok := v.(string); ok && value != "" {
+			return value
+		}
+	}
+
+	if value, ok := db.Statement.Context.Value(contextKey).(string); ok {
+		return value
+	}
+	return ""
+}
+
+// warehouseOf return the warehouse requested by scope, context or model
+func warehouseOf(db *gorm.DB) string {
+	if warehouse := settingOf(db, warehouseSettingKey, warehouseKey{}); warehouse != "" {
+		return warehouse
+	}
+
+	if db.Statement.Schema != nil {
+		if warehouser, ok := reflect.New(db.Statement.Schema.ModelType).Interface().(Warehouser); ok {
+			return warehouser.SnowflakeWarehouse()
+		}
+	}
+	return ""
+}
+
+// secondaryRolesOf return the secondary roles requested by scope or context
+func secondaryRolesOf(db *gorm.DB) ([]string, bool) {
+	if v, ok := db.Get(secondaryRolesSettingKey); ok {
+		if roles, ok := v.([]string); ok {
+			return roles, true
+		}
+	}
+
+	roles, ok := db.Statement.Context.Value(secondaryRolesKey{}).([]string)
+	return roles, ok
+}
+
+func secondaryRolesSQL(roles []string) string {
+	switch {
+	case len(roles) == 0 || (len(roles) == 1 && strings.EqualFold(roles[0], "NONE")):
+		return "NONE"
+	case len(roles) == 1 && strings.EqualFold(roles[0], "ALL"):
+		return "ALL"
+	}
+
+	quoted := make([]string, len(roles))
+	for idx, role := range roles {
+		quoted[idx] = quoteIdentifier(role)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// restoreSecondaryRoles build the statement restoring the CURRENT_SECONDARY_ROLES() output,
+// e.g. {"roles":"ANALYST,AUDITOR","value":"ALL"}, errUnrestorable when the output isn't understood
+func restoreSecondaryRoles(current string) (string, error) {
+	var secondary struct {
+		Roles *string `json:"roles"`
+		Value *string `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(current), &secondary); err != nil || secondary.Roles == nil || secondary.Value == nil {
+		return "", fmt.Errorf("%w: unexpected CURRENT_SECONDARY_ROLES() %q", errUnrestorable, current)
+	}
+
+	switch {
+	case strings.EqualFold(*secondary.Value, "ALL"):
+		return "USE SECONDARY ROLES ALL", nil
+	case *secondary.Roles == "":
+		return "USE SECONDARY ROLES NONE", nil
+	}
+
+	roles := strings.Split(*secondary.Roles, ",")
+	for idx, role := range roles {
+		roles[idx] = quoteName(strings.TrimSpace(role))
+	}
+	return "USE SECONDARY ROLES " + strings.Join(roles, ", "), nil
+}
+
+// restoreCurrent read the current value with query, restoreWith returns the statement restoring it
+func restoreCurrent(query string, restoreWith func(value string) (string, error)) func(context.Context, gorm.ConnPool) (string, error) {
+	return func(ctx context.Context, conn gorm.ConnPool) (string, error) {
+		var current sql.NullString
+		if err := conn.QueryRowContext(ctx, query).Scan(&current); err != nil {
+			return "", err
+		}
+		if !current.Valid {
+			// e.g. no warehouse, there is no USE statement back to NULL
+			return "", errUnrestorable
+		}
+		return restoreWith(current.String)
+	}
+}
+
+// restoreParameter read the session's value of the parameter name, set at login (from the DSN, Params or
+// SessionParameters) or inherited from the user or account, which UNSET goes back to
+func restoreParameter(name string) func(context.Context, gorm.ConnPool) (string, error) {
+	return func(ctx context.Context, conn gorm.ConnPool) (string, error) {
+		rows, err := conn.QueryContext(ctx, "SHOW PARAMETERS LIKE "+sessionValue(name)+" IN SESSION")
+		if err != nil {
+			return "", err
+		}
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		if err != nil {
+			return "", err
+		}
+
+		for rows.Next() {
+			var (
+				values = make([]sql.NullString, len(columns))
+				dest   = make([]interface{}, len(columns))
+				param  = map[string]string{}
+			)
+			for idx := range values {
+				dest[idx] = &values[idx]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				return "", err
+			}
+			for idx, column := range columns {
+				param[strings.ToLower(column)] = values[idx].String
+			}
+
+			if !strings.EqualFold(param["key"], name) {
+				continue
+			}
+			if strings.EqualFold(param["level"], "SESSION") {
+				return "ALTER SESSION SET " + name + " = " + sessionValueOf(param["value"], param["type"]), nil
+			}
+			return "ALTER SESSION UNSET " + name, nil
+		}
+
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("snowflake: unknown session parameter %q", name)
+	}
+}
+
+// applySession pin a connection and apply the session overrides before anything else runs
+func applySession(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if db.Error != nil || db.DryRun || ctx == nil || ctx.Value(sessionAppliedKey{}) != nil {
+		return
+	}
+
+	overrides, err := sessionOverrides(db)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	} else if len(overrides) == 0 {
+		return
+	}
+
+	state := &sessionState{pool: db.Statement.ConnPool, ctx: ctx}
+	if pooler, ok := db.Statement.ConnPool.(connPooler); ok {
+		if state.conn, err = pooler.Conn(ctx); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		db.Statement.ConnPool = state.conn
+	}
+
+	// nested statements (e.g. associations) share the pinned connection and its applied session
+	db.Statement.Context = context.WithValue(ctx, sessionAppliedKey{}, true)
+	db.InstanceSet(sessionStateKey, state)
+
+	// the query ID requested with gosnowflake.WithQueryIDChan is the statement's, not an override's
+	ctx = gosnowflake.WithQueryIDChan(ctx, nil)
+
+	for _, override := range overrides {
+		restore, err := override.restore(ctx, db.Statement.ConnPool)
+		if errors.Is(err, errUnrestorable) {
+			// only connections taken from the pool can be discarded, a transaction's would keep the override
+			if state.conn == nil {
+				_ = db.AddError(fmt.Errorf("%w after %s on a pinned connection", err, override.apply))
+				return
+			}
+			if err != errUnrestorable {
+				db.Logger.Warn(ctx, "%v, the connection is discarded after the statement", err)
+			}
+			state.discard = true
+		} else if err != nil {
+			_ = db.AddError(err)
+			return
+		} else if restore == "" {
+			// already in the requested state
+			continue
+		}
+
+		if _, err := db.Statement.ConnPool.ExecContext(ctx, override.apply); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		if restore != "" {
+			state.restores = append([]string{restore}, state.restores...)
+		}
+	}
+}
+
+// restoreSession undo the session overrides and release the pinned connection, the restores run even when the
+// statement's context is done, a pooled connection that isn't restored is discarded
+func restoreSession(deferRelease bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(sessionStateKey)
+		if !ok {
+			return
+		}
+		state := v.(*sessionState)
+
+		var target gorm.ConnPool = state.pool
+		if state.conn != nil {
+			target = state.conn
+		}
+
+		restored, ctx := !state.discard, gosnowflake.WithQueryIDChan(withoutCancel(state.ctx), nil)
+		for _, sql := range state.restores {
+			if !restored {
+				break
+			}
+			if _, err := target.ExecContext(ctx, sql); err != nil {
+				_ = db.AddError(err)
+				restored = false
+				break
+			}
+		}
+
+		if pinned, ok := state.pool.(*pinnedConn); ok && !restored {
+			// the connection is WithSession's, discarded once it returns
+			pinned.discard = true
+		}
+
+		if state.conn != nil {
+			release := state.conn.Close
+			if !restored {
+				release = func() error { return discardConn(state.conn) }
+			}
+
+			if deferRelease {
+				// Close blocks until the returned rows are closed
+				go release()
+			} else {
+				_ = release()
+			}
+		}
+
+		db.Statement.ConnPool = state.pool
+		db.Statement.Context = state.ctx
+		db.Statement.Settings.Delete(fmt.Sprintf("%p", db.Statement) + sessionStateKey)
+	}
+}
+
+// discardConn close conn and its driver connection instead of returning it to the pool
+func discardConn(conn *sql.Conn) error {
+	_ = conn.Raw(func(interface{}) error {
+		return driver.ErrBadConn
+	})
+	return conn.Close()
+}
+
+// detachedContext the values of a context, never done, like context.WithoutCancel
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// withoutCancel ctx with its values, not canceled when ctx is
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// quoteIdentifier leave plain identifiers as is, double-quote anything else
+func quoteIdentifier(name string) string {
+	if identifierRegexp.MatchString(name) {
+		return name
+	}
+	return quoteName(name)
+}
+
+// quoteName double-quote an exact (case-sensitive) object name, e.g. as returned by CURRENT_WAREHOUSE()
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sessionValue render a session parameter value, quoting anything that isn't a number or boolean
+func sessionValue(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+
+	switch strings.ToUpper(value) {
+	case "TRUE", "FALSE":
+		return value
+	}
+
+	return quoteString(value)
+}
+
+// sessionValueOf render a value of SHOW PARAMETERS of type dataType, quoting strings even when they look like numbers
+func sessionValueOf(value, dataType string) string {
+	switch strings.ToUpper(dataType) {
+	case "BOOLEAN", "NUMBER":
+		return sessionValue(value)
+	}
+	return quoteString(value)
+}
+
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,228 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type sessionUser struct {
+	ID   int
+	Name string
+}
+
+var showParameterColumns = []string{"key", "value", "default", "level", "description", "type"}
+
+func TestSessionParametersRestoreSessionValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		query := statement.query
+		switch {
+		case strings.Contains(query, "LIKE 'TIMEZONE'"):
+			return fakeRow(showParameterColumns, "TIMEZONE", "UTC", "America/Los_Angeles", "SESSION", "", "STRING"), nil
+		case strings.Contains(query, "LIKE 'QUERY_TAG'"):
+			return fakeRow(showParameterColumns, "QUERY_TAG", "", "", "", "", "STRING"), nil
+		case strings.HasPrefix(query, "SELECT"):
+			// the statement's context is canceled while it runs, the session is restored anyway
+			cancel()
+		}
+		return nil, nil
+	})
+
+	ctx = WithSessionParameters(ctx, map[string]string{"TIMEZONE": "Europe/Paris", "query_tag": "report"})
+	var users []sessionUser
+	if err := db.WithContext(ctx).Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	assertQueries(t, d.queries(),
+		"SHOW PARAMETERS LIKE 'QUERY_TAG' IN SESSION",
+		"ALTER SESSION SET QUERY_TAG = 'report'",
+		"SHOW PARAMETERS LIKE 'TIMEZONE' IN SESSION",
+		"ALTER SESSION SET TIMEZONE = 'Europe/Paris'",
+		"SELECT * FROM session_users",
+		"ALTER SESSION SET TIMEZONE = 'UTC'",
+		"ALTER SESSION UNSET QUERY_TAG",
+	)
+	if closed := d.closedConns(); closed != 0 {
+		t.Errorf("%d connections discarded", closed)
+	}
+}
+
+func TestSessionDiscardedWhenNotRestored(t *testing.T) {
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		query := statement.query
+		switch {
+		case strings.HasPrefix(query, "SHOW PARAMETERS"):
+			return fakeRow(showParameterColumns, "TIMEZONE", "UTC", "", "SESSION", "", "STRING"), nil
+		case query == "ALTER SESSION SET TIMEZONE = 'UTC'":
+			return nil, errors.New("restore failed")
+		}
+		return nil, nil
+	})
+
+	ctx := WithSessionParameters(context.Background(), map[string]string{"TIMEZONE": "Europe/Paris"})
+	var users []sessionUser
+	if err := db.WithContext(ctx).Find(&users).Error; err == nil || !strings.Contains(err.Error(), "restore failed") {
+		t.Fatalf("error = %v", err)
+	}
+	if closed := d.closedConns(); closed != 1 {
+		t.Errorf("%d connections discarded, want 1", closed)
+	}
+
+	// the next statement gets a new connection
+	if err := db.Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if d.conns != 2 {
+		t.Errorf("%d connections opened, want 2", d.conns)
+	}
+}
+
+func TestWithSessionPinsOneConnection(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+
+	// take a second connection from the pool, WithSession must not alternate between them
+	other, err := db.Statement.ConnPool.(connPooler).Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	err = WithSession(db, func(tx *gorm.DB) error {
+		var users []sessionUser
+		for idx := 0; idx < 3; idx++ {
+			if err := tx.Find(&users).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Transaction(func(tx *gorm.DB) error {
+			return tx.Exec("CREATE TEMPORARY TABLE tmp_ids (id NUMBER)").Error
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements := d.statements
+	if len(statements) != 6 {
+		t.Fatalf("queries = %v", d.queries())
+	}
+	for _, statement := range statements {
+		if statement.conn != statements[0].conn {
+			t.Errorf("%s ran on connection %d, want %d", statement.query, statement.conn, statements[0].conn)
+		}
+	}
+	if closed := d.closedConns(); closed != 0 {
+		t.Errorf("%d connections discarded", closed)
+	}
+}
+
+func TestWithSessionDiscardedWhenNotRestored(t *testing.T) {
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		query := statement.query
+		switch {
+		case strings.HasPrefix(query, "SHOW PARAMETERS"):
+			return fakeRow(showParameterColumns, "TIMEZONE", "UTC", "", "SESSION", "", "STRING"), nil
+		case query == "ALTER SESSION SET TIMEZONE = 'UTC'":
+			return nil, errors.New("restore failed")
+		}
+		return nil, nil
+	})
+
+	ctx := WithSessionParameters(context.Background(), map[string]string{"TIMEZONE": "Europe/Paris"})
+	err := WithSession(db, func(tx *gorm.DB) error {
+		var users []sessionUser
+		if err := tx.WithContext(ctx).Find(&users).Error; err == nil || !strings.Contains(err.Error(), "restore failed") {
+			t.Errorf("error = %v", err)
+		}
+		// the connection is still pinned until WithSession returns
+		return tx.Find(&users).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements := d.statements
+	for _, statement := range statements {
+		if statement.conn != statements[0].conn {
+			t.Errorf("%s ran on connection %d, want %d", statement.query, statement.conn, statements[0].conn)
+		}
+	}
+	if closed := d.closedConns(); closed != 1 {
+		t.Errorf("%d connections discarded, want 1", closed)
+	}
+
+	// the next statement gets a new connection
+	var users []sessionUser
+	if err := db.Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if last := d.statements[len(d.statements)-1]; last.conn == statements[0].conn {
+		t.Errorf("%s ran on the discarded connection", last.query)
+	}
+}
+
+func TestWarehouseWithoutCurrentWarehouse(t *testing.T) {
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		query := statement.query
+		if query == "SELECT CURRENT_WAREHOUSE()" {
+			return fakeRow([]string{"CURRENT_WAREHOUSE()"}, nil), nil
+		}
+		return nil, nil
+	})
+
+	var users []sessionUser
+	if err := db.Scopes(UseWarehouse("XL_WH")).Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(), "SELECT CURRENT_WAREHOUSE()", "USE WAREHOUSE XL_WH", "SELECT * FROM session_users")
+	if closed := d.closedConns(); closed != 1 {
+		t.Errorf("%d connections discarded, want 1", closed)
+	}
+
+	// a pinned connection can't be discarded, the override is refused
+	err := WithSession(db, func(tx *gorm.DB) error {
+		return tx.Scopes(UseWarehouse("XL_WH")).Find(&users).Error
+	})
+	if !errors.Is(err, errUnrestorable) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestSecondaryRolesUnexpectedCurrent(t *testing.T) {
+	current := `{"roles":"ANALYST,AUDITOR","value":""}`
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		query := statement.query
+		if query == "SELECT CURRENT_SECONDARY_ROLES()" {
+			return fakeRow([]string{"CURRENT_SECONDARY_ROLES()"}, current), nil
+		}
+		return nil, nil
+	})
+
+	var users []sessionUser
+	if err := db.Scopes(UseSecondaryRoles("NONE")).Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries(),
+		"SELECT CURRENT_SECONDARY_ROLES()",
+		"USE SECONDARY ROLES NONE",
+		"SELECT * FROM session_users",
+		`USE SECONDARY ROLES "ANALYST", "AUDITOR"`,
+	)
+
+	// the original roles aren't guessed from an unexpected output, the connection is discarded instead
+	current = `["ANALYST"]`
+	if err := db.Scopes(UseSecondaryRoles("NONE")).Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	assertQueries(t, d.queries()[4:], "SELECT CURRENT_SECONDARY_ROLES()", "USE SECONDARY ROLES NONE", "SELECT * FROM session_users")
+	if closed := d.closedConns(); closed != 1 {
+		t.Errorf("%d connections discarded, want 1", closed)
+	}
+}
@@ -2,8 +2,11 @@ package snowflake
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
+	"math"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -14,11 +17,23 @@ import (
 	"gorm.io/gorm/migrator"
 	"gorm.io/gorm/schema"
 
-	_ "github.com/snowflakedb/gosnowflake"
+	"github.com/snowflakedb/gosnowflake"
 )
 
 const (
 	SnowflakeDriverName = "snowflake"
+	// DefaultMaxStringSize the 16MB maximum length of a VARCHAR
+	DefaultMaxStringSize = 16777216
+)
+
+// IDStrategy how auto increment primary keys get their values back into the models
+type IDStrategy int
+
+const (
+	// IDChangeTracking use IDENTITY columns and read the generated values back with CHANGES after the insert (default)
+	IDChangeTracking IDStrategy = iota
+	// IDSequence back the columns with a sequence, a block of NEXTVAL is fetched and assigned before the insert
+	IDSequence
 )
 
 type Dialector struct {
@@ -29,6 +44,30 @@ type Config struct {
 	DriverName string
 	DSN        string
 	Conn       gorm.ConnPool
+
+	// Snowflake structured connection config, used instead of DSN when set
+	// (e.g. PrivateKey with AuthTypeJwt, AuthTypeOAuth, AuthTypeExternalBrowser)
+	Snowflake *gosnowflake.Config
+	// TokenProvider is called for every new connection so rotating OAuth tokens are picked up by long-lived pools
+	TokenProvider TokenProvider
+	// SessionParameters applied to every pooled connection at login (e.g. TIMEZONE, QUERY_TAG),
+	// override them per statement with WithSessionParameters
+	SessionParameters map[string]string
+	// IDStrategy for auto increment primary keys, IDSequence avoids relying on CHANGES for IDs and supports upserts
+	IDStrategy IDStrategy
+	// ArrayBindThreshold rows from which Create binds one array per column instead of a VALUES tuple per row,
+	// 0 uses DefaultArrayBindThreshold and a negative value disables array binding
+	ArrayBindThreshold int
+	// CreateBatchWorkers connections snowflake.CreateInBatches creates batches on concurrently, 0 or 1 for one at a time
+	CreateBatchWorkers int
+	// DefaultStringSize length of string columns without a size tag, 0 for VARCHAR(256) primary keys and indexed
+	// strings and VARCHAR (the maximum length) otherwise
+	DefaultStringSize int
+	// MaxStringSize largest VARCHAR(n) created, longer strings are VARCHAR, 0 uses DefaultMaxStringSize
+	// (set 134217728 on accounts with 128MB strings). BINARY(n) goes up to half of it.
+	MaxStringSize int
+	// Types the custom types registered by the application, created by Open and New when nil
+	Types *TypeRegistry
 }
 
 func (dialector Dialector) Name() string {
@@ -40,19 +79,29 @@ func Open(dsn string) gorm.Dialector {
 		Config: &Config{
 			DSN:        dsn,
 			DriverName: SnowflakeDriverName,
+			Types:      NewTypeRegistry(),
 		},
 	}
 }
 
 func New(config Config) gorm.Dialector {
+	if config.Types == nil {
+		config.Types = NewTypeRegistry()
+	}
 	return &Dialector{Config: &config}
 }
 
 func (dialector Dialector) Initialize(db *gorm.DB) (err error) {
 	log.Println("creating connection...")
 	// register callbacks
-	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	callbackConfig := &callbacks.Config{}
+	callbacks.RegisterDefaultCallbacks(db, callbackConfig)
 	_ = db.Callback().Create().Replace("gorm:create", Create)
+	_ = db.Callback().Query().Replace("gorm:query", Query)
+	_ = db.Callback().Update().Replace("gorm:update", Update(callbackConfig))
+	_ = db.Callback().Delete().Replace("gorm:delete", Delete(callbackConfig))
+	registerSessionCallbacks(db)
+	registerTimeCallbacks(db)
 
 	if dialector.DriverName == "" {
 		dialector.DriverName = SnowflakeDriverName
@@ -60,6 +109,12 @@ func (dialector Dialector) Initialize(db *gorm.DB) (err error) {
 
 	if dialector.Conn != nil {
 		db.ConnPool = dialector.Conn
+	} else if dialector.Snowflake != nil || dialector.TokenProvider != nil || len(dialector.SessionParameters) > 0 {
+		c, err := dialector.connector()
+		if err != nil {
+			return err
+		}
+		db.ConnPool = sql.OpenDB(c)
 	} else {
 		db.ConnPool, err = sql.Open(dialector.DriverName, dialector.DSN)
 		if err != nil {
@@ -73,6 +128,57 @@ func (dialector Dialector) Initialize(db *gorm.DB) (err error) {
 	return
 }
 
+// connector build the connector from the structured config, falling back to parsing the DSN
+func (dialector Dialector) connector() (*connector, error) {
+	config := dialector.Snowflake
+	if config == nil {
+		parsed, err := gosnowflake.ParseDSN(dialector.DSN)
+		if err != nil {
+			return nil, err
+		}
+		config = parsed
+	}
+
+	c := newConnector(*config, dialector.TokenProvider)
+	if len(dialector.SessionParameters) > 0 {
+		params := make(map[string]*string, len(c.config.Params)+len(dialector.SessionParameters))
+		for k, v := range c.config.Params {
+			params[k] = v
+		}
+		for k, v := range dialector.SessionParameters {
+			value := v
+			params[strings.ToUpper(k)] = &value
+		}
+		c.config.Params = params
+	}
+	return c, nil
+}
+
+// usesSequence if the field is an auto increment primary key backed by a sequence
+func (config *Config) usesSequence(field *schema.Field) bool {
+	return config.IDStrategy == IDSequence && field != nil && field.AutoIncrement && field.PrimaryKey
+}
+
+// sequenceName of the sequence backing an auto increment column
+func sequenceName(table, column string) string {
+	return table + "_" + column + "_seq"
+}
+
+// configOf return the config of the snowflake dialector used by db
+func configOf(db *gorm.DB) *Config {
+	switch dialector := db.Dialector.(type) {
+	case *Dialector:
+		if dialector.Config != nil {
+			return dialector.Config
+		}
+	case Dialector:
+		if dialector.Config != nil {
+			return dialector.Config
+		}
+	}
+	return &Config{}
+}
+
 func (dialector Dialector) ClauseBuilders() map[string]clause.ClauseBuilder {
 	return map[string]clause.ClauseBuilder{
 		"LIMIT": func(c clause.Clause, builder clause.Builder) {
@@ -119,7 +225,13 @@ func (dialector Dialector) Migrator(db *gorm.DB) gorm.Migrator {
 	}}}
 }
 
+// BindVarTo bind unsigned integers above MaxInt64 as text, database/sql only converts the ones fitting an int64
 func (dialector Dialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	if _, ok := v.(driver.Valuer); !ok && len(stmt.Vars) > 0 {
+		if rv := reflect.Indirect(reflect.ValueOf(v)); (rv.Kind() == reflect.Uint || rv.Kind() == reflect.Uint64) && rv.Uint() > math.MaxInt64 {
+			stmt.Vars[len(stmt.Vars)-1] = strconv.FormatUint(rv.Uint(), 10)
+		}
+	}
 	writer.WriteByte('?')
 }
 
@@ -133,45 +245,108 @@ func (dialector Dialector) Explain(sql string, vars ...interface{}) string {
 }
 
 func (dialector Dialector) DataTypeOf(field *schema.Field) string {
-	switch field.DataType {
+	if mapping, ok := dialector.Types.lookUp(field); ok && mapping.DataType != "" {
+		return mapping.DataType
+	}
+
+	switch schema.DataType(strings.ToLower(string(field.DataType))) {
 	case schema.Bool:
 		return "BOOLEAN"
 	case schema.Int, schema.Uint:
-		var sqlType string
-		switch {
-		case field.Size < 16:
-			sqlType = "SMALLINT"
-		case field.Size < 31:
-			sqlType = "INT"
-		default:
-			sqlType = "BIGINT"
-		}
-
-		if field.AutoIncrement {
+		sqlType := integerType(field)
+		if field.AutoIncrement && dialector.IDStrategy != IDSequence {
 			return sqlType + " IDENTITY(1,1)"
 		}
 		return sqlType
 	case schema.Float:
+		if field.Precision > 0 || field.Scale > 0 {
+			return numberType(field)
+		}
 		return "FLOAT"
 	case schema.String:
+		if isJSONSerialized(field) {
+			return "VARIANT"
+		}
+		if isDecimalSerialized(field) {
+			return numberType(field)
+		}
+
 		size := field.Size
 		hasIndex := field.TagSettings["INDEX"] != "" || field.TagSettings["UNIQUE"] != ""
-		if (field.PrimaryKey || hasIndex) && size == 0 {
+		switch {
+		case size > 0:
+		case dialector.DefaultStringSize > 0:
+			size = dialector.DefaultStringSize
+		case field.PrimaryKey || hasIndex:
 			size = 256
 		}
-		if size > 0 && size <= 4000 {
+		if size > 0 && size <= dialector.maxStringSize() {
 			return fmt.Sprintf("VARCHAR(%d)", size)
 		}
 		return "VARCHAR"
-	case schema.Time:
-		return "TIMESTAMP_NTZ"
+	case schema.Time, "date", "time_of_day", "datetime", "timestamp", "timestamp_ntz", "timestamp_ltz", "timestamp_tz":
+		return timeTypeOf(field)
 	case schema.Bytes:
+		if isRawMessage(field) {
+			return "VARIANT"
+		}
+		if field.Size > 0 && field.Size <= dialector.maxStringSize()/2 {
+			return fmt.Sprintf("BINARY(%d)", field.Size)
+		}
 		return "VARBINARY"
+	case "variant", "object", "array", "geography", "geometry":
+		return strings.ToUpper(string(field.DataType))
+	case "vector":
+		return fmt.Sprintf("VECTOR(FLOAT, %d)", field.Size)
+	case "decimal":
+		return numberType(field)
+	case "json", "jsonb":
+		// maps and slices of type:json or with a json GormDataType (e.g. datatypes.JSONMap)
+		return "VARIANT"
 	}
 
 	return string(field.DataType)
 }
 
+// checkDataType an error for the fields DataTypeOf can't give a valid type to, checked before creating or altering
+// their columns
+func checkDataType(field *schema.Field) error {
+	if strings.EqualFold(string(field.DataType), "vector") && field.Size <= 0 {
+		return fmt.Errorf("snowflake: vector field %s.%s needs its dimension as size tag, e.g. `gorm:\"size:768\"`",
+			field.Schema.Name, field.Name)
+	}
+	return nil
+}
+
+// maxStringSize largest VARCHAR(n), the largest BINARY(n) is half of it
+func (config *Config) maxStringSize() int {
+	if config.MaxStringSize > 0 {
+		return config.MaxStringSize
+	}
+	return DefaultMaxStringSize
+}
+
+// integerType the NUMBER(p,0) holding every value of the Go integer type of field (Snowflake stores every integer
+// type as a NUMBER), or of its precision tag
+func integerType(field *schema.Field) string {
+	var precision int
+	switch {
+	case field.Precision > 0:
+		precision = field.Precision
+	case field.Size > 0 && field.Size <= 8:
+		precision = 3
+	case field.Size > 0 && field.Size <= 16:
+		precision = 5
+	case field.Size > 0 && field.Size <= 32:
+		precision = 10
+	case field.DataType == schema.Uint:
+		precision = 20
+	default:
+		precision = 19
+	}
+	return fmt.Sprintf("NUMBER(%d,0)", precision)
+}
+
 // no support for savepoint
 func (dialectopr Dialector) SavePoint(tx *gorm.DB, name string) error {
 	return nil
@@ -0,0 +1,374 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timeOfDayLayout = "15:04:05.999999999"
+)
+
+// Date a DATE, a day without time or zone. The zero Date is NULL.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateOf the date of t in its location
+func DateOf(t time.Time) Date {
+	var d Date
+	d.Year, d.Month, d.Day = t.Date()
+	return d
+}
+
+// ParseDate the date of s, e.g. "2024-02-29"
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return Date{}, err
+	}
+	return DateOf(t), nil
+}
+
+// In the start of the day in loc
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// IsZero if the date is NULL
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Date) UnmarshalText(data []byte) (err error) {
+	*d, err = ParseDate(string(data))
+	return err
+}
+
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.String(), nil
+}
+
+// Scan the DATE, gosnowflake returns it as midnight UTC
+func (d *Date) Scan(src interface{}) (err error) {
+	switch data := src.(type) {
+	case nil:
+		*d = Date{}
+	case time.Time:
+		*d = DateOf(data)
+	case string:
+		*d, err = ParseDate(data)
+	case []byte:
+		*d, err = ParseDate(string(data))
+	default:
+		err = fmt.Errorf("snowflake: can't scan %T into Date", src)
+	}
+	return err
+}
+
+func (Date) GormDataType() string {
+	return "date"
+}
+
+// TimeOfDay a TIME, a wall clock without date or zone, use a *TimeOfDay for NULL
+type TimeOfDay struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// TimeOfDayOf the wall clock of t in its location
+func TimeOfDayOf(t time.Time) TimeOfDay {
+	return TimeOfDay{Hour: t.Hour(), Minute: t.Minute(), Second: t.Second(), Nanosecond: t.Nanosecond()}
+}
+
+// ParseTimeOfDay the time of s, e.g. "13:45:00" or "13:45:00.250"
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse(timeOfDayLayout, s)
+	if err != nil {
+		return TimeOfDay{}, err
+	}
+	return TimeOfDayOf(t), nil
+}
+
+// On the time on date d in loc
+func (t TimeOfDay) On(d Date, loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, t.Hour, t.Minute, t.Second, t.Nanosecond, loc)
+}
+
+func (t TimeOfDay) String() string {
+	return t.On(Date{Year: 1970, Month: time.January, Day: 1}, time.UTC).Format(timeOfDayLayout)
+}
+
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *TimeOfDay) UnmarshalText(data []byte) (err error) {
+	*t, err = ParseTimeOfDay(string(data))
+	return err
+}
+
+func (t TimeOfDay) Value() (driver.Value, error) {
+	return t.String(), nil
+}
+
+// Scan the TIME, gosnowflake returns it on 1970-01-01 UTC
+func (t *TimeOfDay) Scan(src interface{}) (err error) {
+	switch data := src.(type) {
+	case nil:
+		*t = TimeOfDay{}
+	case time.Time:
+		*t = TimeOfDayOf(data)
+	case string:
+		*t, err = ParseTimeOfDay(data)
+	case []byte:
+		*t, err = ParseTimeOfDay(string(data))
+	default:
+		err = fmt.Errorf("snowflake: can't scan %T into TimeOfDay", src)
+	}
+	return err
+}
+
+func (TimeOfDay) GormDataType() string {
+	return "time_of_day"
+}
+
+// timeTypeOf the DATE, TIME or TIMESTAMP type of field, from its snowflake tag (e.g. `snowflake:"timestamp_tz"`),
+// its type tag or Go type, TIMESTAMP_NTZ by default, with its precision. The type tag "time" is gorm's time.Time,
+// a time.Time stored as TIME takes the snowflake tag.
+func timeTypeOf(field *schema.Field) string {
+	dataType := strings.ToUpper(strings.TrimSpace(field.Tag.Get("snowflake")))
+	if dataType == "" && field.DataType != schema.Time {
+		dataType = strings.ToUpper(string(field.DataType))
+	}
+
+	switch dataType {
+	case "", "DATETIME", "TIMESTAMPNTZ", "TIMESTAMP_NTZ":
+		dataType = "TIMESTAMP_NTZ"
+	case "TIMESTAMPLTZ", "TIMESTAMP_LTZ":
+		dataType = "TIMESTAMP_LTZ"
+	case "TIMESTAMPTZ", "TIMESTAMP_TZ":
+		dataType = "TIMESTAMP_TZ"
+	case "TIME_OF_DAY":
+		dataType = "TIME"
+	case "DATE":
+		return dataType
+	}
+
+	if field.Precision > 0 {
+		return fmt.Sprintf("%s(%d)", dataType, field.Precision)
+	}
+	return dataType
+}
+
+// timeLayout the text layout of times bound for field, utc when they're TIMESTAMP_NTZ wall clocks in UTC like gosnowflake binds them
+func timeLayout(db *gorm.DB, field *schema.Field) (layout string, utc bool) {
+	dataType := "TIMESTAMP_NTZ"
+	if field != nil {
+		dataType = strings.ToUpper(db.Dialector.DataTypeOf(field))
+	}
+
+	switch {
+	case strings.HasPrefix(dataType, "TIMESTAMP_TZ"), strings.HasPrefix(dataType, "TIMESTAMPTZ"),
+		strings.HasPrefix(dataType, "TIMESTAMP_LTZ"), strings.HasPrefix(dataType, "TIMESTAMPLTZ"):
+		return "2006-01-02 15:04:05.999999999 -07:00", false
+	case dataType == "DATE":
+		return dateLayout, false
+	case strings.HasPrefix(dataType, "TIME") && !strings.HasPrefix(dataType, "TIMESTAMP"):
+		return timeOfDayLayout, false
+	}
+	return "2006-01-02 15:04:05.999999999", true
+}
+
+// formatTime text of t for the column type of field
+func formatTime(db *gorm.DB, field *schema.Field, t time.Time) string {
+	layout, utc := timeLayout(db, field)
+	if utc {
+		t = t.UTC()
+	}
+	return t.Format(layout)
+}
+
+// bindTime the time value bound for field as text in its zone when the column keeps one (or is a DATE or TIME),
+// gosnowflake binds times as TIMESTAMP_NTZ which Snowflake converts with the session's TIMEZONE
+func bindTime(db *gorm.DB, field *schema.Field, value interface{}) interface{} {
+	if field == nil {
+		return value
+	}
+
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v == nil {
+			return value
+		}
+		t = *v
+	default:
+		return value
+	}
+
+	if _, utc := timeLayout(db, field); utc {
+		return value
+	}
+	return formatTime(db, field, t)
+}
+
+// bindsTimes if the model has times bound as text by bindTime
+func bindsTimes(db *gorm.DB) bool {
+	if db.Statement.Schema == nil {
+		return false
+	}
+
+	for _, field := range db.Statement.Schema.Fields {
+		if _, utc := timeLayout(db, field); field.GORMDataType == schema.Time && !utc {
+			return true
+		}
+	}
+	return false
+}
+
+// bindTimeValues bind the times of the created rows with bindTime
+func bindTimeValues(db *gorm.DB, values clause.Values) {
+	if db.Statement.Schema == nil {
+		return
+	}
+
+	for idx, column := range values.Columns {
+		field := db.Statement.Schema.LookUpField(column.Name)
+		if field == nil || field.GORMDataType != schema.Time {
+			continue
+		}
+		for _, value := range values.Values {
+			value[idx] = bindTime(db, field, value[idx])
+		}
+	}
+}
+
+// bindTimeAssignments bind the times of the updated columns with bindTime
+func bindTimeAssignments(db *gorm.DB, set clause.Set) {
+	if db.Statement.Schema == nil {
+		return
+	}
+
+	for idx, assignment := range set {
+		if field := lookUpField(db.Statement.Schema, assignment.Column.Name); field != nil && field.GORMDataType == schema.Time {
+			set[idx].Value = bindTime(db, field, assignment.Value)
+		}
+	}
+}
+
+func registerTimeCallbacks(db *gorm.DB) {
+	_ = db.Callback().Query().Before("gorm:query").Register("snowflake:bind_time_conditions", bindTimeConditions)
+	_ = db.Callback().Update().Before("gorm:update").Register("snowflake:bind_time_conditions", bindTimeConditions)
+	_ = db.Callback().Delete().Before("gorm:delete").Register("snowflake:bind_time_conditions", bindTimeConditions)
+	_ = db.Callback().Row().Before("gorm:row").Register("snowflake:bind_time_conditions", bindTimeConditions)
+}
+
+// bindTimeConditions bind the times compared with the columns of the model in the WHERE clause with bindTime,
+// for the conditions naming their column (struct, map and clause conditions), SQL strings bind them as is
+func bindTimeConditions(db *gorm.DB) {
+	if db.Error != nil || !bindsTimes(db) {
+		return
+	}
+
+	c, ok := db.Statement.Clauses["WHERE"]
+	if !ok {
+		return
+	}
+	if where, ok := c.Expression.(clause.Where); ok {
+		where.Exprs = bindTimeExprs(db, where.Exprs)
+		c.Expression = where
+		db.Statement.Clauses["WHERE"] = c
+	}
+}
+
+// bindTimeExprs the conditions with their times bound by bindTime, in a new slice as the clause may be shared
+func bindTimeExprs(db *gorm.DB, exprs []clause.Expression) []clause.Expression {
+	converted := make([]clause.Expression, len(exprs))
+	for idx, expr := range exprs {
+		switch e := expr.(type) {
+		case clause.Eq:
+			e.Value = bindConditionTime(db, e.Column, e.Value)
+			expr = e
+		case clause.Neq:
+			e.Value = bindConditionTime(db, e.Column, e.Value)
+			expr = e
+		case clause.Gt:
+			e.Value = bindConditionTime(db, e.Column, e.Value)
+			expr = e
+		case clause.Gte:
+			e.Value = bindConditionTime(db, e.Column, e.Value)
+			expr = e
+		case clause.Lt:
+			e.Value = bindConditionTime(db, e.Column, e.Value)
+			expr = e
+		case clause.Lte:
+			e.Value = bindConditionTime(db, e.Column, e.Value)
+			expr = e
+		case clause.IN:
+			values := make([]interface{}, len(e.Values))
+			for i, value := range e.Values {
+				values[i] = bindConditionTime(db, e.Column, value)
+			}
+			e.Values = values
+			expr = e
+		case clause.AndConditions:
+			e.Exprs = bindTimeExprs(db, e.Exprs)
+			expr = e
+		case clause.OrConditions:
+			e.Exprs = bindTimeExprs(db, e.Exprs)
+			expr = e
+		case clause.NotConditions:
+			e.Exprs = bindTimeExprs(db, e.Exprs)
+			expr = e
+		}
+		converted[idx] = expr
+	}
+	return converted
+}
+
+// bindConditionTime bind a time compared with column, a column of the model's table
+func bindConditionTime(db *gorm.DB, column interface{}, value interface{}) interface{} {
+	var name string
+	switch c := column.(type) {
+	case clause.Column:
+		if c.Raw || (c.Table != "" && c.Table != clause.CurrentTable && c.Table != db.Statement.Table) {
+			return value
+		}
+		name = c.Name
+	case string:
+		name = c
+	default:
+		return value
+	}
+
+	field := lookUpField(db.Statement.Schema, name)
+	if field == nil || field.GORMDataType != schema.Time {
+		return value
+	}
+	return bindTime(db, field, value)
+}
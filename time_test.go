@@ -0,0 +1,41 @@
+package snowflake
+
+import (
+	"testing"
+	"time"
+)
+
+type zonedEvent struct {
+	ID      int64
+	At      time.Time `snowflake:"timestamp_tz"`
+	Created time.Time
+}
+
+func TestConditionsBindZonedTimes(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+	at := time.Date(2024, 3, 1, 10, 30, 0, 0, time.FixedZone("", 2*3600))
+
+	var events []zonedEvent
+	if err := db.Where(&zonedEvent{At: at}).Or("at > ?", at).Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Where(map[string]interface{}{"at": []time.Time{at}}).Where("created", at).Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	statements := d.statements
+	assertQueries(t, []string{statements[0].query, statements[1].query},
+		"SELECT * FROM zoned_events WHERE zoned_events.at = ? OR at > ?",
+		"SELECT * FROM zoned_events WHERE at = ? AND created = ?",
+	)
+	for idx, want := range []interface{}{"2024-03-01 10:30:00 +02:00", at} {
+		if got := statements[0].args[idx]; got != want {
+			t.Errorf("first query arg %d = %#v, want %#v", idx, got, want)
+		}
+	}
+	for idx, want := range []interface{}{"2024-03-01 10:30:00 +02:00", at} {
+		if got := statements[1].args[idx]; got != want {
+			t.Errorf("second query arg %d = %#v, want %#v", idx, got, want)
+		}
+	}
+}
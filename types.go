@@ -0,0 +1,301 @@
+package snowflake
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"gorm.io/gorm/utils"
+)
+
+// TypeMapping how the values of a custom type are stored, see TypeRegistry.Register
+type TypeMapping struct {
+	// DataType the column type, e.g. NUMBER(18,4) or VARCHAR(26)
+	DataType string
+	// BindExpr the expression converting bound values into the column type on create and update, e.g. TO_VARIANT(?),
+	// bound as is when empty
+	BindExpr string
+	// Scan convert a value returned by gosnowflake (never nil) into a value assignable to the field, e.g. a
+	// time.Duration from the digits of a NUMBER, NULL sets the zero value (nil for pointers)
+	Scan func(src interface{}) (interface{}, error)
+}
+
+// TypeRegistry the custom types of a dialector, by Go type or gorm DataType name, e.g.
+//
+//	dialector := snowflake.New(snowflake.Config{DSN: dsn}).(*snowflake.Dialector)
+//	dialector.Types.Register(Money{}, snowflake.TypeMapping{DataType: "NUMBER(18,4)"})
+//	dialector.Types.Register("ulid", snowflake.TypeMapping{DataType: "BINARY(16)"})
+//
+// Mappings are used to create and alter columns, to bind the values of creates, upserts and updates, and to scan
+// the fields of models queried with Find, First, Take and Joins or returned by creates, updates and deletes (not those
+// of Raw(...).Scan and ScanRows, which gorm parses without callbacks). Mappings registered later apply to the next
+// statements.
+type TypeRegistry struct {
+	mu     sync.RWMutex
+	byType map[reflect.Type]TypeMapping
+	byName map[string]TypeMapping
+}
+
+// NewTypeRegistry an empty registry
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{byType: map[reflect.Type]TypeMapping{}, byName: map[string]TypeMapping{}}
+}
+
+// Register map a Go type (a value of it, e.g. Money{}, or its reflect.Type) or a gorm DataType name (a string, the
+// GormDataType of a type or a type tag, e.g. `gorm:"type:ulid"`) to mapping. Pointers share the mapping of their type,
+// the Go type of a field goes before its DataType name, except for type tags.
+func (r *TypeRegistry) Register(typeOrName interface{}, mapping TypeMapping) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch key := typeOrName.(type) {
+	case string:
+		r.byName[strings.ToLower(key)] = mapping
+	case schema.DataType:
+		r.byName[strings.ToLower(string(key))] = mapping
+	case reflect.Type:
+		r.byType[indirectType(key)] = mapping
+	default:
+		r.byType[indirectType(reflect.TypeOf(key))] = mapping
+	}
+}
+
+// lookUp the mapping of field
+func (r *TypeRegistry) lookUp(field *schema.Field) (TypeMapping, bool) {
+	if r == nil || field == nil {
+		return TypeMapping{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := field.TagSettings["TYPE"]; !ok && field.FieldType != nil {
+		if mapping, ok := r.byType[indirectType(field.FieldType)]; ok {
+			return mapping, true
+		}
+	}
+	mapping, ok := r.byName[strings.ToLower(string(field.DataType))]
+	return mapping, ok
+}
+
+// bindsExprs if a field of sch has a mapping with a bind expression
+func (r *TypeRegistry) bindsExprs(sch *schema.Schema) bool {
+	if sch == nil {
+		return false
+	}
+
+	for _, field := range sch.Fields {
+		if mapping, ok := r.lookUp(field); ok && mapping.BindExpr != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// scanRows rows converting the columns of fields whose mappings have a Scan converter before they're set, the
+// fields are resolved like gorm.Scan does: by column name in the destination's schema or joined relations
+func scanRows(db *gorm.DB, rows *sql.Rows) gorm.Rows {
+	types, sch := configOf(db).Types, scannedSchema(db)
+	if sch == nil {
+		return rows
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return rows
+	}
+
+	converters := make([]func(src interface{}) (interface{}, error), len(columns))
+	converts := false
+	for idx, column := range columns {
+		field := scannedField(sch, column)
+		if field == nil || field.Serializer != nil {
+			continue
+		}
+		if mapping, ok := types.lookUp(field); ok && mapping.Scan != nil {
+			converters[idx], converts = mapping.Scan, true
+		}
+	}
+	if !converts {
+		return rows
+	}
+	return &convertedRows{Rows: rows, converters: converters}
+}
+
+// scannedSchema the schema of the models rows are scanned into, gorm parses the destination when it isn't the model
+func scannedSchema(db *gorm.DB) *schema.Schema {
+	sch, rv := db.Statement.Schema, db.Statement.ReflectValue
+	if sch == nil || !rv.IsValid() {
+		return sch
+	}
+
+	modelType := rv.Type()
+	for modelType.Kind() == reflect.Slice || modelType.Kind() == reflect.Array ||
+		modelType.Kind() == reflect.Ptr || modelType.Kind() == reflect.Interface {
+		if modelType.Kind() == reflect.Interface {
+			return sch
+		}
+		modelType = modelType.Elem()
+	}
+	if modelType.Kind() != reflect.Struct || modelType == sch.ModelType {
+		return sch
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(db.Statement.Dest); err != nil {
+		return nil
+	}
+	return stmt.Schema
+}
+
+// scannedField the field a column is scanned into, e.g. Owner__timeout for the timeout of the joined Owner
+func scannedField(sch *schema.Schema, column string) *schema.Field {
+	if field := sch.LookUpField(column); field != nil {
+		return field
+	}
+
+	names := utils.SplitNestedRelationName(column)
+	if len(names) < 2 {
+		return nil
+	}
+	for _, name := range names[:len(names)-1] {
+		rel, ok := sch.Relationships.Relations[name]
+		if !ok {
+			return nil
+		}
+		sch = rel.FieldSchema
+	}
+	return sch.LookUpField(names[len(names)-1])
+}
+
+// convertedRows rows whose columns with a converter are scanned as returned by gosnowflake, converted and set in
+// the destinations gorm scans them into
+type convertedRows struct {
+	*sql.Rows
+	converters []func(src interface{}) (interface{}, error)
+}
+
+func (rows *convertedRows) Scan(dest ...interface{}) error {
+	var (
+		scanned = make([]interface{}, len(dest))
+		values  = make([]interface{}, len(dest))
+	)
+	for idx := range dest {
+		values[idx] = dest[idx]
+		if idx < len(rows.converters) && rows.converters[idx] != nil {
+			values[idx] = &scanned[idx]
+		}
+	}
+	if err := rows.Rows.Scan(values...); err != nil {
+		return err
+	}
+
+	for idx, convert := range rows.converters {
+		if convert == nil || idx >= len(dest) {
+			continue
+		}
+
+		value := scanned[idx]
+		if value != nil {
+			var err error
+			if value, err = convert(value); err != nil {
+				return err
+			}
+		}
+		if err := assignScanned(dest[idx], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignScanned set a converted value in a scan destination, a pointer to the value (or to a pointer to it, for NULL)
+// or a sql.Scanner, NULL sets the zero value
+func assignScanned(dest interface{}, value interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("snowflake: can't scan into %T", dest)
+	}
+
+	dv = dv.Elem()
+	if value == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+
+	sv := reflect.ValueOf(value)
+	for {
+		switch {
+		case sv.Type().AssignableTo(dv.Type()):
+			dv.Set(sv)
+			return nil
+		case sv.Kind() == dv.Kind() && sv.Type().ConvertibleTo(dv.Type()):
+			dv.Set(sv.Convert(dv.Type()))
+			return nil
+		case dv.Kind() == reflect.Ptr:
+			if dv.IsNil() {
+				dv.Set(reflect.New(dv.Type().Elem()))
+			}
+			dv = dv.Elem()
+		default:
+			if scanner, ok := dv.Addr().Interface().(sql.Scanner); ok {
+				return scanner.Scan(value)
+			}
+			return fmt.Errorf("snowflake: can't scan %T into %s", value, dv.Type())
+		}
+	}
+}
+
+// bindExprAssignments convert the updated values with the bind expressions of their mappings
+func bindExprAssignments(db *gorm.DB, set clause.Set) {
+	if db.Statement.Schema == nil {
+		return
+	}
+
+	types := configOf(db).Types
+	for idx, assignment := range set {
+		mapping, ok := types.lookUp(lookUpField(db.Statement.Schema, assignment.Column.Name))
+		if !ok || mapping.BindExpr == "" {
+			continue
+		}
+
+		switch assignment.Value.(type) {
+		case clause.Expression, gorm.Valuer:
+			// already an expression
+		default:
+			set[idx].Value = clause.Expr{SQL: mapping.BindExpr, Vars: []interface{}{bindValue(assignment.Value)}}
+		}
+	}
+}
+
+// Query gorm query callback, scanning the fields of registered types with their converters (see scanRows)
+func Query(db *gorm.DB) {
+	if db.Error == nil {
+		callbacks.BuildQuerySQL(db)
+
+		if !db.DryRun && db.Error == nil {
+			rows, err := db.Statement.ConnPool.QueryContext(db.Statement.Context, db.Statement.SQL.String(), db.Statement.Vars...)
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			defer func() {
+				_ = db.AddError(rows.Close())
+			}()
+			gorm.Scan(scanRows(db, rows), db, 0)
+		}
+	}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,83 @@
+package snowflake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+type typedOwner struct {
+	ID      int64
+	Timeout time.Duration
+	Grace   *time.Duration
+}
+
+type typedJob struct {
+	ID      int64
+	Timeout time.Duration
+	OwnerID int64
+	Owner   typedOwner
+}
+
+// seconds scan durations stored in seconds
+func seconds(src interface{}) (interface{}, error) {
+	n, err := strconv.ParseInt(fmt.Sprint(src), 10, 64)
+	return time.Duration(n) * time.Second, err
+}
+
+func TestTypesScanConvertedValues(t *testing.T) {
+	types := NewTypeRegistry()
+	db, _ := openFake(t, Config{Types: types}, func(statement fakeStatement) (*fakeRows, error) {
+		if strings.Contains(statement.query, "CHANGES(") {
+			return fakeRow([]string{"id", "timeout", "grace"}, "3", "45", "5"), nil
+		}
+		if strings.HasPrefix(statement.query, "SELECT") {
+			return fakeRow([]string{"id", "timeout", "owner_id", "Owner__id", "Owner__timeout", "Owner__grace"},
+				int64(1), "90", int64(2), int64(2), "30", nil), nil
+		}
+		return nil, nil
+	})
+
+	var job typedJob
+	if err := db.Joins("Owner").First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job.Timeout != 90 || job.Owner.Timeout != 30 {
+		t.Fatalf("unregistered timeouts = %v, %v, want 90ns, 30ns", job.Timeout, job.Owner.Timeout)
+	}
+
+	types.Register(time.Duration(0), TypeMapping{DataType: "NUMBER(19,0)", Scan: seconds})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var job typedJob
+			if err := db.Joins("Owner").First(&job).Error; err != nil {
+				errs <- err
+			} else if job.Timeout != 90*time.Second || job.Owner.Timeout != 30*time.Second || job.Owner.Grace != nil {
+				errs <- fmt.Errorf("registered timeouts = %v, %v, %v, want 1m30s, 30s, nil", job.Timeout, job.Owner.Timeout, job.Owner.Grace)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	owner := typedOwner{Timeout: 45 * time.Second}
+	if err := db.Clauses(clause.Returning{}).Create(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	if owner.ID != 3 || owner.Timeout != 45*time.Second || owner.Grace == nil || *owner.Grace != 5*time.Second {
+		t.Errorf("created owner = %+v, want ID 3, 45s and 5s", owner)
+	}
+}
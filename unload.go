@@ -0,0 +1,171 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snowflakedb/gosnowflake"
+	"gorm.io/gorm"
+)
+
+// UnloadResult outcome of an Unload
+type UnloadResult struct {
+	RowsUnloaded int64
+	// Files written to the destination directory, empty when unloading to an io.Writer
+	Files []string
+}
+
+// Unload export the result of a query to local files without scanning it in Go: the SELECT built from db is
+// copied with COPY INTO a temporary internal stage and the files are downloaded with GET, e.g.
+//
+//	result, err := snowflake.Unload(db.Model(&Event{}).Where("day = ?", day), "/exports/events", snowflake.LoadCSV)
+//
+// dest is either a directory, receiving the (gzipped) files as Snowflake splits them, or an io.Writer,
+// receiving one uncompressed file. CSV files have a header, JSON files hold one object per row.
+func Unload(db *gorm.DB, dest interface{}, format LoadFormat) (*UnloadResult, error) {
+	var (
+		dir    string
+		writer io.Writer
+	)
+	switch d := dest.(type) {
+	case string:
+		dir = d
+	case io.Writer:
+		writer = d
+	default:
+		return nil, fmt.Errorf("snowflake: unsupported unload destination %T, expected a directory or an io.Writer", dest)
+	}
+
+	if format == "" {
+		format = LoadCSV
+	}
+
+	var fileFormat string
+	switch format {
+	case LoadCSV:
+		fileFormat = `TYPE = CSV FIELD_OPTIONALLY_ENCLOSED_BY = '"' NULL_IF = ()`
+	case LoadJSON:
+		fileFormat = "TYPE = JSON"
+	case LoadParquet:
+		fileFormat = "TYPE = PARQUET"
+	default:
+		return nil, fmt.Errorf("snowflake: unsupported unload format %q", format)
+	}
+	if writer != nil && format != LoadParquet {
+		fileFormat += " COMPRESSION = NONE"
+	}
+
+	// build the SELECT without running it
+	var rows []map[string]interface{}
+	query := db.Session(&gorm.Session{DryRun: true}).Find(&rows)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	sql, vars := query.Statement.SQL.String(), query.Statement.Vars
+
+	result := &UnloadResult{}
+	err := WithSession(db, func(tx *gorm.DB) error {
+		stage, err := createTemporaryStage(tx)
+		if err != nil {
+			return err
+		}
+		defer tx.Exec("DROP STAGE IF EXISTS " + stage)
+
+		// COPY INTO doesn't take bind variables, run the query and copy its result instead. Session overrides
+		// (e.g. UseWarehouse) run statements after it, so its result is scanned by query ID.
+		source := "(" + strings.TrimSuffix(sql, ";") + ")"
+		if len(vars) > 0 {
+			queryID := make(chan string, 1)
+			rows, err := tx.WithContext(gosnowflake.WithQueryIDChan(tx.Statement.Context, queryID)).Raw(sql, vars...).Rows()
+			if err != nil {
+				return err
+			}
+			if err := rows.Close(); err != nil {
+				return err
+			}
+
+			select {
+			case id := <-queryID:
+				source = "TABLE(RESULT_SCAN(" + quoteString(id) + "))"
+			default:
+				return errors.New("snowflake: the unloaded query returned no query ID")
+			}
+		}
+
+		selected := "SELECT * FROM " + source
+		if format == LoadJSON {
+			selected = "SELECT OBJECT_CONSTRUCT(*) FROM " + source
+		}
+
+		copySQL := fmt.Sprintf("COPY INTO @%s/data FROM (%s) FILE_FORMAT = (%s)", stage, selected, fileFormat)
+		if format != LoadJSON {
+			copySQL += " HEADER = TRUE"
+		}
+		if writer != nil {
+			copySQL += " SINGLE = TRUE MAX_FILE_SIZE = 5368709120"
+		}
+
+		records, err := queryRecords(tx, copySQL)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			result.RowsUnloaded += recordInt(record["rows_unloaded"])
+		}
+
+		target := dir
+		if writer != nil {
+			if target, err = ioutil.TempDir("", "gorm-snowflake-unload"); err != nil {
+				return err
+			}
+			defer os.RemoveAll(target)
+		} else if target, err = filepath.Abs(target); err != nil {
+			return err
+		} else if err = os.MkdirAll(target, 0o755); err != nil {
+			return err
+		}
+
+		downloaded, err := queryRecords(tx, fmt.Sprintf("GET @%s '%s/'", stage, fileURL(target)))
+		if err != nil {
+			return err
+		}
+
+		var files []string
+		for _, record := range downloaded {
+			files = append(files, filepath.Join(target, recordString(record["file"])))
+		}
+		sort.Strings(files)
+
+		if writer != nil {
+			return copyFiles(writer, files)
+		}
+		result.Files = files
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// copyFiles write the files to w one after the other
+func copyFiles(w io.Writer, files []string) error {
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package snowflake
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// unloadHandler answers the COPY INTO of an Unload with rows unloaded and its GET by writing files into the
+// requested directory
+func unloadHandler(t *testing.T, files map[string]string) func(statement fakeStatement) (*fakeRows, error) {
+	return func(statement fakeStatement) (*fakeRows, error) {
+		switch query := statement.query; {
+		case strings.HasPrefix(query, "COPY INTO"):
+			return fakeRow([]string{"rows_unloaded", "input_bytes", "output_bytes"}, "2", "20", "20"), nil
+		case strings.HasPrefix(query, "GET"):
+			dir := query[strings.Index(query, "'file://")+len("'file://") : strings.LastIndex(query, "/'")]
+			rows := &fakeRows{columns: []string{"file", "size", "status", "message"}}
+			for name, content := range files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Error(err)
+				}
+				rows.values = append(rows.values, []driver.Value{name, "1", "DOWNLOADED", ""})
+			}
+			return rows, nil
+		}
+		return nil, nil
+	}
+}
+
+func TestUnloadToWriter(t *testing.T) {
+	db, d := openFake(t, Config{}, unloadHandler(t, map[string]string{"data_0_0_0.csv": "id,name\n1,alice\n2,bob\n"}))
+
+	var buf bytes.Buffer
+	result, err := Unload(db.Table("events"), &buf, LoadCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := d.queries()
+	assertQueries(t, queries,
+		"CREATE TEMPORARY STAGE gorm_stage_",
+		"COPY INTO @gorm_stage_",
+		"GET @gorm_stage_",
+		"DROP STAGE IF EXISTS gorm_stage_",
+	)
+	if !strings.HasSuffix(queries[1], `/data FROM (SELECT * FROM (SELECT * FROM events)) FILE_FORMAT = (TYPE = CSV FIELD_OPTIONALLY_ENCLOSED_BY = '"' NULL_IF = () COMPRESSION = NONE) HEADER = TRUE SINGLE = TRUE MAX_FILE_SIZE = 5368709120`) {
+		t.Errorf("copy = %s", queries[1])
+	}
+	if buf.String() != "id,name\n1,alice\n2,bob\n" || result.RowsUnloaded != 2 || len(result.Files) > 0 {
+		t.Errorf("unloaded %q, result = %+v", buf.String(), result)
+	}
+}
+
+func TestUnloadBoundQueryToDirectory(t *testing.T) {
+	db, d := openFake(t, Config{}, unloadHandler(t, map[string]string{
+		"data_0_1_0.json.gz": "b",
+		"data_0_0_0.json.gz": "a",
+	}))
+
+	dir := filepath.Join(t.TempDir(), "exports")
+	result, err := Unload(db.Table("events").Where("name = ?", "alice"), dir, LoadJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := d.queries()
+	assertQueries(t, queries,
+		"CREATE TEMPORARY STAGE gorm_stage_",
+		"SELECT * FROM events WHERE name = ?",
+		"COPY INTO @gorm_stage_",
+		"GET @gorm_stage_",
+		"DROP STAGE IF EXISTS gorm_stage_",
+	)
+	if args := d.statements[1].args; !reflect.DeepEqual(args, []interface{}{"alice"}) {
+		t.Errorf("args = %v", args)
+	}
+	// COPY INTO doesn't take bind variables, it copies the result of the bound query
+	if !strings.HasSuffix(queries[2], "/data FROM (SELECT OBJECT_CONSTRUCT(*) FROM TABLE(RESULT_SCAN('fake-query-2'))) FILE_FORMAT = (TYPE = JSON)") {
+		t.Errorf("copy = %s", queries[2])
+	}
+	if !strings.HasSuffix(queries[3], "'file://"+filepath.ToSlash(dir)+"/'") {
+		t.Errorf("get = %s", queries[3])
+	}
+
+	want := []string{filepath.Join(dir, "data_0_0_0.json.gz"), filepath.Join(dir, "data_0_1_0.json.gz")}
+	if result.RowsUnloaded != 2 || !reflect.DeepEqual(result.Files, want) {
+		t.Errorf("result = %+v", result)
+	}
+	for _, file := range want {
+		if _, err := os.Stat(file); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestUnloadDropsStageOnError(t *testing.T) {
+	copyErr := errors.New("copy failed")
+	db, d := openFake(t, Config{}, func(statement fakeStatement) (*fakeRows, error) {
+		if strings.HasPrefix(statement.query, "COPY INTO") {
+			return nil, copyErr
+		}
+		return nil, nil
+	})
+
+	var buf bytes.Buffer
+	if _, err := Unload(db.Table("events"), &buf, LoadParquet); !errors.Is(err, copyErr) {
+		t.Errorf("error = %v", err)
+	}
+	queries := d.queries()
+	assertQueries(t, queries,
+		"CREATE TEMPORARY STAGE gorm_stage_",
+		"COPY INTO @gorm_stage_",
+		"DROP STAGE IF EXISTS gorm_stage_",
+	)
+	if !strings.HasSuffix(queries[1], " FILE_FORMAT = (TYPE = PARQUET) HEADER = TRUE SINGLE = TRUE MAX_FILE_SIZE = 5368709120") {
+		t.Errorf("copy = %s", queries[1])
+	}
+}
+
+func TestUnloadRefusesInvalidArguments(t *testing.T) {
+	db, d := openFake(t, Config{}, nil)
+	if _, err := Unload(db.Table("events"), 42, LoadCSV); err == nil {
+		t.Error("invalid destination accepted")
+	}
+	if _, err := Unload(db.Table("events"), t.TempDir(), "XML"); err == nil {
+		t.Error("invalid format accepted")
+	}
+	if queries := d.queries(); len(queries) > 0 {
+		t.Errorf("queries = %v", queries)
+	}
+}
@@ -0,0 +1,171 @@
+package snowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+// Variant a VARIANT value, kept as its JSON text
+type Variant json.RawMessage
+
+// Object an OBJECT value
+type Object map[string]interface{}
+
+// Array an ARRAY value
+type Array []interface{}
+
+// NewVariant the VARIANT holding v encoded as JSON
+func NewVariant(v interface{}) (Variant, error) {
+	data, err := json.Marshal(v)
+	return Variant(data), err
+}
+
+// Unmarshal decode the VARIANT into dest
+func (v Variant) Unmarshal(dest interface{}) error {
+	return json.Unmarshal(v, dest)
+}
+
+func (v Variant) MarshalJSON() ([]byte, error) {
+	if v == nil {
+		return []byte("null"), nil
+	}
+	return v, nil
+}
+
+func (v *Variant) UnmarshalJSON(data []byte) error {
+	*v = append((*v)[0:0], data...)
+	return nil
+}
+
+func (v Variant) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return string(v), nil
+}
+
+func (v *Variant) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		*v = nil
+	case string:
+		*v = Variant(data)
+	case []byte:
+		*v = append(Variant(nil), data...)
+	default:
+		return fmt.Errorf("snowflake: can't scan %T into Variant", src)
+	}
+	return nil
+}
+
+func (Variant) GormDataType() string {
+	return "variant"
+}
+
+// GormValue parse the JSON text, so updates and conditions compare VARIANTs
+func (v Variant) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	return jsonExpr("PARSE_JSON(?)", v)
+}
+
+func (o Object) Value() (driver.Value, error) {
+	return jsonValue(o == nil, o)
+}
+
+func (o *Object) Scan(src interface{}) error {
+	return scanJSON(src, o)
+}
+
+func (Object) GormDataType() string {
+	return "object"
+}
+
+func (o Object) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	return jsonExpr("PARSE_JSON(?)::OBJECT", o)
+}
+
+func (a Array) Value() (driver.Value, error) {
+	return jsonValue(a == nil, a)
+}
+
+func (a *Array) Scan(src interface{}) error {
+	return scanJSON(src, a)
+}
+
+func (Array) GormDataType() string {
+	return "array"
+}
+
+func (a Array) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	return jsonExpr("PARSE_JSON(?)::ARRAY", a)
+}
+
+// jsonValue JSON text of v, NULL when isNil
+func jsonValue(isNil bool, v interface{}) (driver.Value, error) {
+	if isNil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// jsonExpr bind the JSON text of a valuer into sql
+func jsonExpr(sql string, valuer driver.Valuer) clause.Expr {
+	value, err := valuer.Value()
+	if err != nil {
+		// fail when the driver reads the value
+		return clause.Expr{SQL: "?", Vars: []interface{}{invalidValue{err}}}
+	} else if value == nil {
+		return clause.Expr{SQL: "NULL"}
+	}
+	return clause.Expr{SQL: sql, Vars: []interface{}{value}}
+}
+
+// invalidValue a value that couldn't be converted
+type invalidValue struct {
+	err error
+}
+
+func (v invalidValue) Value() (driver.Value, error) {
+	return nil, v.err
+}
+
+// scanJSON decode JSON text returned for semi-structured columns
+func scanJSON(src interface{}, dest interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		return json.Unmarshal([]byte("null"), dest)
+	case string:
+		return json.Unmarshal([]byte(data), dest)
+	case []byte:
+		return json.Unmarshal(data, dest)
+	}
+	return fmt.Errorf("snowflake: can't scan %T into %T", src, dest)
+}
+
+// isJSONSerialized if the field is stored as JSON by gorm's json serializer
+func isJSONSerialized(field *schema.Field) bool {
+	name := field.TagSettings["JSON"]
+	if name == "" {
+		name = field.TagSettings["SERIALIZER"]
+	}
+	return strings.EqualFold(name, "json")
+}
+
+// isRawMessage if the field holds a json.RawMessage
+func isRawMessage(field *schema.Field) bool {
+	t := field.FieldType
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == rawMessageType
+}
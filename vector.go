@@ -0,0 +1,70 @@
+package snowflake
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Vector a VECTOR(FLOAT, n) value, n is the size tag of the field, e.g. `gorm:"size:768"`
+type Vector []float32
+
+// VectorMetric the function comparing two vectors
+type VectorMetric string
+
+const (
+	// CosineSimilarity VECTOR_COSINE_SIMILARITY, higher is nearer
+	CosineSimilarity VectorMetric = "VECTOR_COSINE_SIMILARITY"
+	// InnerProduct VECTOR_INNER_PRODUCT, higher is nearer
+	InnerProduct VectorMetric = "VECTOR_INNER_PRODUCT"
+	// L2Distance VECTOR_L2_DISTANCE, lower is nearer
+	L2Distance VectorMetric = "VECTOR_L2_DISTANCE"
+)
+
+func (v Vector) Value() (driver.Value, error) {
+	return jsonValue(v == nil, v)
+}
+
+func (v *Vector) Scan(src interface{}) error {
+	return scanJSON(src, v)
+}
+
+func (Vector) GormDataType() string {
+	return "vector"
+}
+
+// GormValue cast the bound array, a vector can't be bound as is
+func (v Vector) GormValue(ctx context.Context, db *gorm.DB) clause.Expr {
+	return jsonExpr(fmt.Sprintf("PARSE_JSON(?)::ARRAY::VECTOR(FLOAT, %d)", len(v)), v)
+}
+
+// VectorSimilarity compare column to vector with metric, e.g. to select the score
+func VectorSimilarity(column string, vector Vector, metric VectorMetric) clause.Expression {
+	return clause.Expr{SQL: string(metric) + "(?, ?)", Vars: []interface{}{clause.Column{Name: column}, vector}}
+}
+
+// NearestTo scope ordering the rows by the similarity of column to vector, nearest first, limit it for the top k, e.g.
+//
+//	db.Scopes(snowflake.NearestTo("embedding", query, snowflake.CosineSimilarity)).Limit(10).Find(&documents)
+func NearestTo(column string, vector Vector, metric VectorMetric) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		switch metric {
+		case CosineSimilarity, InnerProduct, L2Distance:
+		default:
+			_ = db.AddError(fmt.Errorf("snowflake: unsupported vector metric %q", metric))
+			return db
+		}
+
+		order := "DESC"
+		if metric == L2Distance {
+			order = "ASC"
+		}
+		return db.Clauses(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "? " + order,
+			Vars: []interface{}{VectorSimilarity(column, vector, metric)},
+		}})
+	}
+}
@@ -0,0 +1,70 @@
+package snowflake
+
+import (
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestNearestTo(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+	query := Vector{1, 0.5, 0}
+
+	for metric, want := range map[VectorMetric]string{
+		CosineSimilarity: "SELECT * FROM sized_documents ORDER BY VECTOR_COSINE_SIMILARITY(embedding, PARSE_JSON(?)::ARRAY::VECTOR(FLOAT, 3)) DESC OFFSET 0 ROW FETCH NEXT 10 ROWS ONLY",
+		InnerProduct:     "SELECT * FROM sized_documents ORDER BY VECTOR_INNER_PRODUCT(embedding, PARSE_JSON(?)::ARRAY::VECTOR(FLOAT, 3)) DESC OFFSET 0 ROW FETCH NEXT 10 ROWS ONLY",
+		L2Distance:       "SELECT * FROM sized_documents ORDER BY VECTOR_L2_DISTANCE(embedding, PARSE_JSON(?)::ARRAY::VECTOR(FLOAT, 3)) ASC OFFSET 0 ROW FETCH NEXT 10 ROWS ONLY",
+	} {
+		var documents []sizedDocument
+		stmt := dry.Scopes(NearestTo("embedding", query, metric)).Limit(10).Find(&documents).Statement
+		if got := stmt.SQL.String(); got != want {
+			t.Errorf("%s:\n got %s\nwant %s", metric, got, want)
+		}
+		if !reflect.DeepEqual(stmt.Vars, []interface{}{"[1,0.5,0]"}) {
+			t.Errorf("%s: vars = %#v", metric, stmt.Vars)
+		}
+	}
+
+	var documents []sizedDocument
+	if err := dry.Scopes(NearestTo("embedding", query, "VECTOR_L1_DISTANCE")).Find(&documents).Error; err == nil {
+		t.Error("unsupported metric accepted")
+	}
+}
+
+func TestVectorSimilarity(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+
+	var documents []sizedDocument
+	stmt := dry.Model(&sizedDocument{}).Select("id, ? AS score", VectorSimilarity("embedding", Vector{1, 2}, InnerProduct)).
+		Find(&documents).Statement
+	if got, want := stmt.SQL.String(), "SELECT id, VECTOR_INNER_PRODUCT(embedding, PARSE_JSON(?)::ARRAY::VECTOR(FLOAT, 2)) AS score FROM sized_documents"; got != want {
+		t.Errorf("\n got %s\nwant %s", got, want)
+	}
+	if !reflect.DeepEqual(stmt.Vars, []interface{}{"[1,2]"}) {
+		t.Errorf("vars = %#v", stmt.Vars)
+	}
+}
+
+func TestCreateCastsVectors(t *testing.T) {
+	db, _ := openFake(t, Config{}, nil)
+	dry := db.Session(&gorm.Session{DryRun: true})
+
+	for _, test := range []struct {
+		document sizedDocument
+		vars     []interface{}
+	}{
+		{sizedDocument{ID: 1, Embedding: Vector{1, 2, 3}}, []interface{}{"[1,2,3]", int64(1)}},
+		{sizedDocument{ID: 2}, []interface{}{nil, int64(2)}},
+	} {
+		stmt := dry.Create(&test.document).Statement
+		if got, want := stmt.SQL.String(), "INSERT INTO sized_documents (embedding,id) SELECT PARSE_JSON($1)::ARRAY::VECTOR(FLOAT, 3),$2 FROM VALUES (?,?);"; got != want {
+			t.Errorf("\n got %s\nwant %s", got, want)
+		}
+		if !reflect.DeepEqual(stmt.Vars, test.vars) {
+			t.Errorf("vars = %#v, want %#v", stmt.Vars, test.vars)
+		}
+	}
+}